@@ -2,15 +2,27 @@ package main
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Debug      bool        `mapstructure:"debug"`
-	DB         DBConfig    `mapstructure:"db"`
-	Initialise bool        `mapstructure:"initialise"`
-	GeoIP      GeoIPConfig `mapstructure:"geoip"`
+	Debug      bool             `mapstructure:"debug"`
+	DB         DBConfig         `mapstructure:"db"`
+	Initialise bool             `mapstructure:"initialise"`
+	GeoIP      GeoIPConfig      `mapstructure:"geoip"`
+	CORS       CORSConfig       `mapstructure:"cors"`
+	Static     StaticConfig     `mapstructure:"static"`
+	Blog       BlogConfig       `mapstructure:"blog"`
+	BGP        BGPConfig        `mapstructure:"bgp"`
+	RPKI       RPKIConfig       `mapstructure:"rpki"`
+	Bogon      BogonConfig      `mapstructure:"bogon"`
+	IRR        IRRConfig        `mapstructure:"irr"`
+	Cloud      CloudConfig      `mapstructure:"cloud"`
+	Reputation ReputationConfig `mapstructure:"reputation"`
+	DNSBL      DNSBLConfig      `mapstructure:"dnsbl"`
+	RDAP       RDAPConfig       `mapstructure:"rdap"`
 }
 
 type DBConfig struct {
@@ -25,6 +37,80 @@ type GeoIPConfig struct {
 	DatabasePath string `mapstructure:"database_path"`
 }
 
+type StaticConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+type BlogConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+type BGPConfig struct {
+	Retention  int      `mapstructure:"retention"`
+	MRTSources []string `mapstructure:"mrt_sources"`
+}
+
+type RPKIConfig struct {
+	Source string `mapstructure:"source"`
+}
+
+type BogonConfig struct {
+	Sources []string `mapstructure:"sources"`
+}
+
+type IRRConfig struct {
+	Sources []string `mapstructure:"sources"`
+}
+
+type CloudConfig struct {
+	Sources []CloudSourceConfig `mapstructure:"sources"`
+}
+
+type CloudSourceConfig struct {
+	Format   string `mapstructure:"format"`
+	Location string `mapstructure:"location"`
+}
+
+type ReputationConfig struct {
+	Lists []ReputationListConfig `mapstructure:"lists"`
+}
+
+type ReputationListConfig struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Location    string `mapstructure:"location"`
+}
+
+type DNSBLConfig struct {
+	Resolvers []string          `mapstructure:"resolvers"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	Zones     []DNSBLZoneConfig `mapstructure:"zones"`
+}
+
+type DNSBLZoneConfig struct {
+	Zone string `mapstructure:"zone"`
+	Type string `mapstructure:"type"`
+}
+
+type RDAPConfig struct {
+	Bootstrap RDAPBootstrapConfig `mapstructure:"bootstrap"`
+}
+
+type RDAPBootstrapConfig struct {
+	DNS  string `mapstructure:"dns"`
+	IPv4 string `mapstructure:"ipv4"`
+	IPv6 string `mapstructure:"ipv6"`
+	ASN  string `mapstructure:"asn"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`
+	MaxAge           int      `mapstructure:"max_age"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+}
+
 func InitConfig() (*Config, error) {
 	viper.AddConfigPath(".")
 	viper.SetConfigName("config")
@@ -56,4 +142,51 @@ func setConfigDefaults() {
 	viper.SetDefault("db.db", "paste")
 	viper.SetDefault("db.user", "")
 	viper.SetDefault("db.password", "")
+	viper.SetDefault("static.path", "")
+	viper.SetDefault("blog.path", "./content/blog")
+	viper.SetDefault("bgp.retention", 7)
+	viper.SetDefault("bgp.mrt_sources", []string{})
+	viper.SetDefault("rpki.source", "https://console.rpki-client.org/vrps.json")
+	viper.SetDefault("bogon.sources", []string{
+		"https://www.team-cymru.org/Services/Bogons/fullbogons-ipv4.txt",
+		"https://www.team-cymru.org/Services/Bogons/fullbogons-ipv6.txt",
+	})
+	viper.SetDefault("irr.sources", []string{
+		"https://ftp.ripe.net/ripe/dbase/split/ripe.db.route.gz",
+		"https://ftp.ripe.net/ripe/dbase/split/ripe.db.route6.gz",
+		"https://ftp.ripe.net/ripe/dbase/split/ripe.db.aut-num.gz",
+		"https://ftp.radb.net/radb/dbase/radb.db.gz",
+	})
+	viper.SetDefault("cloud.sources", []map[string]string{
+		{"format": "aws", "location": "https://ip-ranges.amazonaws.com/ip-ranges.json"},
+		{"format": "gcp", "location": "https://www.gstatic.com/ipranges/cloud.json"},
+		{"format": "cloudflare", "location": "https://api.cloudflare.com/client/v4/ips"},
+		{"format": "fastly", "location": "https://api.fastly.com/public-ip-list"},
+		{"format": "oracle", "location": "https://docs.oracle.com/iaas/tools/public_ip_ranges.json"},
+	})
+	viper.SetDefault("reputation.lists", []map[string]string{
+		{"name": "tor-exit", "description": "Tor exit nodes", "location": "https://check.torproject.org/torbulkexitlist"},
+		{"name": "spamhaus-drop", "description": "Spamhaus Don't Route Or Peer (IPv4)", "location": "https://www.spamhaus.org/drop/drop_v4.json"},
+		{"name": "spamhaus-drop-v6", "description": "Spamhaus Don't Route Or Peer (IPv6)", "location": "https://www.spamhaus.org/drop/drop_v6.json"},
+		{"name": "firehol-level1", "description": "FireHOL level 1", "location": "https://raw.githubusercontent.com/firehol/blocklist-ipsets/master/firehol_level1.netset"},
+	})
+	viper.SetDefault("dnsbl.resolvers", []string{})
+	viper.SetDefault("dnsbl.timeout", "5s")
+	viper.SetDefault("dnsbl.zones", []map[string]string{
+		{"zone": "zen.spamhaus.org", "type": "ip"},
+		{"zone": "b.barracudacentral.org", "type": "ip"},
+		{"zone": "bl.spamcop.net", "type": "ip"},
+		{"zone": "psbl.surriel.com", "type": "ip"},
+		{"zone": "bl.mailspike.net", "type": "ip"},
+		{"zone": "dbl.spamhaus.org", "type": "domain"},
+	})
+	viper.SetDefault("rdap.bootstrap.dns", "https://data.iana.org/rdap/dns.json")
+	viper.SetDefault("rdap.bootstrap.ipv4", "https://data.iana.org/rdap/ipv4.json")
+	viper.SetDefault("rdap.bootstrap.ipv6", "https://data.iana.org/rdap/ipv6.json")
+	viper.SetDefault("rdap.bootstrap.asn", "https://data.iana.org/rdap/asn.json")
+	viper.SetDefault("cors.allowed_origins", []string{})
+	viper.SetDefault("cors.allowed_methods", []string{"GET", "POST"})
+	viper.SetDefault("cors.allowed_headers", []string{"Accept", "Content-Type"})
+	viper.SetDefault("cors.max_age", 600)
+	viper.SetDefault("cors.allow_credentials", false)
 }
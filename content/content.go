@@ -0,0 +1,13 @@
+package content
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:blog
+var files embed.FS
+
+func Blog() (fs.FS, error) {
+	return fs.Sub(files, "blog")
+}
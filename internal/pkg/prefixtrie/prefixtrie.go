@@ -0,0 +1,200 @@
+package prefixtrie
+
+import (
+	"net/netip"
+)
+
+// Trie is a path-compressed binary trie keyed by IP prefix, with separate
+// roots for IPv4 and IPv6. Multiple values may be stored against a prefix
+type Trie[T any] struct {
+	root4 *node[T]
+	root6 *node[T]
+	count int
+}
+
+type node[T any] struct {
+	prefix   netip.Prefix
+	values   []T
+	children [2]*node[T]
+}
+
+type Match[T any] struct {
+	Prefix netip.Prefix
+	Values []T
+}
+
+func New[T any]() *Trie[T] {
+	return &Trie[T]{}
+}
+
+// Len returns the number of prefixes with values stored in the trie
+func (t *Trie[T]) Len() int {
+	return t.count
+}
+
+func (t *Trie[T]) root(addr netip.Addr) **node[T] {
+	if addr.Is4() {
+		return &t.root4
+	}
+
+	return &t.root6
+}
+
+func (t *Trie[T]) Insert(prefix netip.Prefix, value T) {
+	prefix = normalise(prefix)
+	leaf := &node[T]{prefix: prefix, values: []T{value}}
+
+	cur := t.root(prefix.Addr())
+	for {
+		n := *cur
+		if n == nil {
+			*cur = leaf
+			t.count++
+			return
+		}
+
+		common := commonBits(n.prefix, prefix)
+		switch {
+		case common == n.prefix.Bits() && common == prefix.Bits():
+			if len(n.values) == 0 {
+				t.count++
+			}
+			n.values = append(n.values, value)
+			return
+		case common == n.prefix.Bits():
+			cur = &n.children[bitAt(prefix.Addr(), common)]
+		case common == prefix.Bits():
+			leaf.children[bitAt(n.prefix.Addr(), common)] = n
+			*cur = leaf
+			t.count++
+			return
+		default:
+			glue := &node[T]{prefix: netip.PrefixFrom(prefix.Addr(), common).Masked()}
+			glue.children[bitAt(n.prefix.Addr(), common)] = n
+			glue.children[bitAt(prefix.Addr(), common)] = leaf
+			*cur = glue
+			t.count++
+			return
+		}
+	}
+}
+
+// Lookup returns all prefixes containing the address, least specific first
+func (t *Trie[T]) Lookup(addr netip.Addr) []Match[T] {
+	addr = addr.Unmap()
+	return t.Covering(netip.PrefixFrom(addr, addr.BitLen()))
+}
+
+// Covering returns all prefixes equal to or containing the prefix, least
+// specific first
+func (t *Trie[T]) Covering(prefix netip.Prefix) []Match[T] {
+	prefix = normalise(prefix)
+
+	var matches []Match[T]
+	n := *t.root(prefix.Addr())
+	for n != nil && n.prefix.Bits() <= prefix.Bits() && n.prefix.Contains(prefix.Addr()) {
+		if len(n.values) > 0 {
+			matches = append(matches, Match[T]{Prefix: n.prefix, Values: n.values})
+		}
+		if n.prefix.Bits() == prefix.Bits() {
+			break
+		}
+		n = n.children[bitAt(prefix.Addr(), n.prefix.Bits())]
+	}
+
+	return matches
+}
+
+// CoveredBy returns all prefixes equal to or contained within the prefix,
+// ordered by address then prefix length
+func (t *Trie[T]) CoveredBy(prefix netip.Prefix) []Match[T] {
+	prefix = normalise(prefix)
+
+	n := *t.root(prefix.Addr())
+	for n != nil && n.prefix.Bits() < prefix.Bits() {
+		if !n.prefix.Contains(prefix.Addr()) {
+			return nil
+		}
+		n = n.children[bitAt(prefix.Addr(), n.prefix.Bits())]
+	}
+
+	if n == nil || !prefix.Contains(n.prefix.Addr()) {
+		return nil
+	}
+
+	var matches []Match[T]
+	n.walk(func(m Match[T]) bool {
+		matches = append(matches, m)
+		return true
+	})
+
+	return matches
+}
+
+// Walk calls fn for every stored prefix, IPv4 first, ordered by address then
+// prefix length. Walking stops if fn returns false
+func (t *Trie[T]) Walk(fn func(m Match[T]) bool) {
+	if t.root4 != nil && !t.root4.walk(fn) {
+		return
+	}
+	if t.root6 != nil {
+		t.root6.walk(fn)
+	}
+}
+
+func (n *node[T]) walk(fn func(m Match[T]) bool) bool {
+	if len(n.values) > 0 && !fn(Match[T]{Prefix: n.prefix, Values: n.values}) {
+		return false
+	}
+
+	for _, child := range n.children {
+		if child != nil && !child.walk(fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalise(prefix netip.Prefix) netip.Prefix {
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+
+	return prefix.Masked()
+}
+
+func bitAt(addr netip.Addr, i int) int {
+	b := addr.AsSlice()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
+
+func commonBits(a, b netip.Prefix) int {
+	max := a.Bits()
+	if b.Bits() < max {
+		max = b.Bits()
+	}
+
+	ab := a.Addr().AsSlice()
+	bb := b.Addr().AsSlice()
+
+	bits := 0
+	for i := 0; i < len(ab) && bits < max; i++ {
+		x := ab[i] ^ bb[i]
+		if x == 0 {
+			bits += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			bits++
+			x <<= 1
+		}
+		break
+	}
+
+	if bits > max {
+		bits = max
+	}
+
+	return bits
+}
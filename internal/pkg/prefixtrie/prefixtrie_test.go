@@ -0,0 +1,204 @@
+package prefixtrie
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func newTestTrie(prefixes ...string) *Trie[string] {
+	trie := New[string]()
+	for _, prefix := range prefixes {
+		trie.Insert(netip.MustParsePrefix(prefix), prefix)
+	}
+
+	return trie
+}
+
+func matchPrefixes(matches []Match[string]) []string {
+	var prefixes []string
+	for _, match := range matches {
+		prefixes = append(prefixes, match.Prefix.String())
+	}
+
+	return prefixes
+}
+
+var testPrefixes = []string{
+	"10.0.0.0/8",
+	"10.1.0.0/16",
+	"10.1.2.0/24",
+	"10.1.3.0/24",
+	"10.128.0.0/9",
+	"192.0.2.0/24",
+	"0.0.0.0/0",
+	"2001:db8::/32",
+	"2001:db8:1::/48",
+	"2001:db8:1:2::/64",
+}
+
+func TestTrieLookup(t *testing.T) {
+	trie := newTestTrie(testPrefixes...)
+
+	tests := []struct {
+		addr     string
+		expected []string
+	}{
+		{addr: "10.1.2.3", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}},
+		{addr: "10.1.4.1", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"}},
+		{addr: "10.200.0.1", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.128.0.0/9"}},
+		{addr: "192.0.2.255", expected: []string{"0.0.0.0/0", "192.0.2.0/24"}},
+		{addr: "198.51.100.1", expected: []string{"0.0.0.0/0"}},
+		// IPv4-mapped addresses are looked up as IPv4
+		{addr: "::ffff:10.1.2.3", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}},
+		{addr: "2001:db8:1:2::1", expected: []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1:2::/64"}},
+		{addr: "2001:db8:2::1", expected: []string{"2001:db8::/32"}},
+		{addr: "2001:db9::1", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			got := matchPrefixes(trie.Lookup(netip.MustParseAddr(test.addr)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrieCovering(t *testing.T) {
+	trie := newTestTrie(testPrefixes...)
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{prefix: "10.1.0.0/16", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"}},
+		{prefix: "10.1.0.0/17", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"}},
+		{prefix: "10.0.0.0/7", expected: []string{"0.0.0.0/0"}},
+		// Host bits are masked
+		{prefix: "10.1.2.77/24", expected: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"}},
+		{prefix: "2001:db8:1::/56", expected: []string{"2001:db8::/32", "2001:db8:1::/48"}},
+		{prefix: "2001:db8::/31", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prefix, func(t *testing.T) {
+			got := matchPrefixes(trie.Covering(netip.MustParsePrefix(test.prefix)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrieCoveredBy(t *testing.T) {
+	trie := newTestTrie(testPrefixes...)
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{prefix: "10.0.0.0/8", expected: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.128.0.0/9"}},
+		{prefix: "10.1.0.0/22", expected: []string{"10.1.2.0/24", "10.1.3.0/24"}},
+		{prefix: "10.1.2.0/24", expected: []string{"10.1.2.0/24"}},
+		{prefix: "10.1.2.0/25", expected: nil},
+		{prefix: "10.64.0.0/10", expected: nil},
+		{prefix: "172.16.0.0/12", expected: nil},
+		{prefix: "2001:db8::/32", expected: []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1:2::/64"}},
+		{prefix: "::/0", expected: []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1:2::/64"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prefix, func(t *testing.T) {
+			got := matchPrefixes(trie.CoveredBy(netip.MustParsePrefix(test.prefix)))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrieInsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		length   int
+		walk     []string
+	}{
+		{
+			name:     "less specific after more specific",
+			prefixes: []string{"10.1.2.0/24", "10.0.0.0/8"},
+			length:   2,
+			walk:     []string{"10.0.0.0/8", "10.1.2.0/24"},
+		},
+		{
+			name:     "siblings sharing glue node",
+			prefixes: []string{"10.1.2.0/24", "10.1.3.0/24", "10.1.2.0/23"},
+			length:   3,
+			walk:     []string{"10.1.2.0/23", "10.1.2.0/24", "10.1.3.0/24"},
+		},
+		{
+			name:     "duplicate prefixes",
+			prefixes: []string{"192.0.2.0/24", "192.0.2.0/24"},
+			length:   1,
+			walk:     []string{"192.0.2.0/24"},
+		},
+		{
+			name:     "IPv4 ordered before IPv6",
+			prefixes: []string{"2001:db8::/32", "192.0.2.0/24", "::ffff:198.51.100.0/120"},
+			length:   3,
+			walk:     []string{"192.0.2.0/24", "198.51.100.0/24", "2001:db8::/32"},
+		},
+		{
+			name:     "host routes",
+			prefixes: []string{"192.0.2.1/32", "192.0.2.0/32", "2001:db8::1/128"},
+			length:   3,
+			walk:     []string{"192.0.2.0/32", "192.0.2.1/32", "2001:db8::1/128"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trie := newTestTrie(test.prefixes...)
+			if trie.Len() != test.length {
+				t.Errorf("expected length %d, got %d", test.length, trie.Len())
+			}
+
+			var walked []string
+			trie.Walk(func(m Match[string]) bool {
+				walked = append(walked, m.Prefix.String())
+				return true
+			})
+			if !reflect.DeepEqual(walked, test.walk) {
+				t.Errorf("expected walk %v, got %v", test.walk, walked)
+			}
+		})
+	}
+}
+
+func TestTrieValues(t *testing.T) {
+	trie := New[int]()
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), 1)
+	trie.Insert(netip.MustParsePrefix("192.0.2.0/24"), 2)
+
+	matches := trie.Lookup(netip.MustParseAddr("192.0.2.1"))
+	if len(matches) != 1 || !reflect.DeepEqual(matches[0].Values, []int{1, 2}) {
+		t.Errorf("expected a single match with values [1 2], got %+v", matches)
+	}
+}
+
+func TestTrieWalkStops(t *testing.T) {
+	trie := newTestTrie(testPrefixes...)
+
+	var walked []string
+	trie.Walk(func(m Match[string]) bool {
+		walked = append(walked, m.Prefix.String())
+		return len(walked) < 3
+	})
+
+	expected := []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("expected %v, got %v", expected, walked)
+	}
+}
@@ -0,0 +1,148 @@
+package util
+
+import (
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+)
+
+// SpecialPurposeBlock is an address block from the IANA special-purpose
+// address registries, or the loaded bogon list
+type SpecialPurposeBlock struct {
+	Prefix    netip.Prefix `json:"prefix"`
+	Name      string       `json:"name"`
+	Reference string       `json:"reference"`
+	Global    bool         `json:"globally_reachable"`
+}
+
+func (b *SpecialPurposeBlock) String() string {
+	return b.Name + " (" + b.Reference + ")"
+}
+
+// specialPurposeBlocks is derived from the IANA IPv4 and IPv6 special-purpose
+// address registries, with multicast space added. The IPv4-mapped block
+// (::ffff:0:0/96) is omitted, as mapped addresses are classified by their
+// IPv4 address
+var specialPurposeBlocks = []SpecialPurposeBlock{
+	{Prefix: netip.MustParsePrefix("0.0.0.0/8"), Name: "This network", Reference: "RFC791"},
+	{Prefix: netip.MustParsePrefix("0.0.0.0/32"), Name: "This host on this network", Reference: "RFC1122"},
+	{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Name: "Private-Use", Reference: "RFC1918"},
+	{Prefix: netip.MustParsePrefix("100.64.0.0/10"), Name: "Shared Address Space (CGNAT)", Reference: "RFC6598"},
+	{Prefix: netip.MustParsePrefix("127.0.0.0/8"), Name: "Loopback", Reference: "RFC1122"},
+	{Prefix: netip.MustParsePrefix("169.254.0.0/16"), Name: "Link Local", Reference: "RFC3927"},
+	{Prefix: netip.MustParsePrefix("172.16.0.0/12"), Name: "Private-Use", Reference: "RFC1918"},
+	{Prefix: netip.MustParsePrefix("192.0.0.0/24"), Name: "IETF Protocol Assignments", Reference: "RFC6890"},
+	{Prefix: netip.MustParsePrefix("192.0.0.0/29"), Name: "IPv4 Service Continuity Prefix", Reference: "RFC7335"},
+	{Prefix: netip.MustParsePrefix("192.0.0.8/32"), Name: "IPv4 dummy address", Reference: "RFC7600"},
+	{Prefix: netip.MustParsePrefix("192.0.0.9/32"), Name: "Port Control Protocol Anycast", Reference: "RFC7723", Global: true},
+	{Prefix: netip.MustParsePrefix("192.0.0.10/32"), Name: "Traversal Using Relays around NAT Anycast", Reference: "RFC8155", Global: true},
+	{Prefix: netip.MustParsePrefix("192.0.0.170/31"), Name: "NAT64/DNS64 Discovery", Reference: "RFC8880"},
+	{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Name: "Documentation (TEST-NET-1)", Reference: "RFC5737"},
+	{Prefix: netip.MustParsePrefix("192.31.196.0/24"), Name: "AS112-v4", Reference: "RFC7535", Global: true},
+	{Prefix: netip.MustParsePrefix("192.52.193.0/24"), Name: "AMT", Reference: "RFC7450", Global: true},
+	{Prefix: netip.MustParsePrefix("192.88.99.0/24"), Name: "Deprecated (6to4 Relay Anycast)", Reference: "RFC7526"},
+	{Prefix: netip.MustParsePrefix("192.168.0.0/16"), Name: "Private-Use", Reference: "RFC1918"},
+	{Prefix: netip.MustParsePrefix("192.175.48.0/24"), Name: "Direct Delegation AS112 Service", Reference: "RFC7534", Global: true},
+	{Prefix: netip.MustParsePrefix("198.18.0.0/15"), Name: "Benchmarking", Reference: "RFC2544"},
+	{Prefix: netip.MustParsePrefix("198.51.100.0/24"), Name: "Documentation (TEST-NET-2)", Reference: "RFC5737"},
+	{Prefix: netip.MustParsePrefix("203.0.113.0/24"), Name: "Documentation (TEST-NET-3)", Reference: "RFC5737"},
+	{Prefix: netip.MustParsePrefix("224.0.0.0/4"), Name: "Multicast", Reference: "RFC5771"},
+	{Prefix: netip.MustParsePrefix("240.0.0.0/4"), Name: "Reserved", Reference: "RFC1112"},
+	{Prefix: netip.MustParsePrefix("255.255.255.255/32"), Name: "Limited Broadcast", Reference: "RFC919"},
+
+	{Prefix: netip.MustParsePrefix("::/128"), Name: "Unspecified Address", Reference: "RFC4291"},
+	{Prefix: netip.MustParsePrefix("::1/128"), Name: "Loopback Address", Reference: "RFC4291"},
+	{Prefix: netip.MustParsePrefix("64:ff9b::/96"), Name: "IPv4-IPv6 Translation", Reference: "RFC6052", Global: true},
+	{Prefix: netip.MustParsePrefix("64:ff9b:1::/48"), Name: "IPv4-IPv6 Translation (local use)", Reference: "RFC8215"},
+	{Prefix: netip.MustParsePrefix("100::/64"), Name: "Discard-Only Address Block", Reference: "RFC6666"},
+	{Prefix: netip.MustParsePrefix("2001::/23"), Name: "IETF Protocol Assignments", Reference: "RFC2928"},
+	{Prefix: netip.MustParsePrefix("2001::/32"), Name: "TEREDO", Reference: "RFC4380"},
+	{Prefix: netip.MustParsePrefix("2001:1::1/128"), Name: "Port Control Protocol Anycast", Reference: "RFC7723", Global: true},
+	{Prefix: netip.MustParsePrefix("2001:1::2/128"), Name: "Traversal Using Relays around NAT Anycast", Reference: "RFC8155", Global: true},
+	{Prefix: netip.MustParsePrefix("2001:2::/48"), Name: "Benchmarking", Reference: "RFC5180"},
+	{Prefix: netip.MustParsePrefix("2001:3::/32"), Name: "AMT", Reference: "RFC7450", Global: true},
+	{Prefix: netip.MustParsePrefix("2001:4:112::/48"), Name: "AS112-v6", Reference: "RFC7535", Global: true},
+	{Prefix: netip.MustParsePrefix("2001:10::/28"), Name: "Deprecated (previously ORCHID)", Reference: "RFC4843"},
+	{Prefix: netip.MustParsePrefix("2001:20::/28"), Name: "ORCHIDv2", Reference: "RFC7343", Global: true},
+	{Prefix: netip.MustParsePrefix("2001:db8::/32"), Name: "Documentation", Reference: "RFC3849"},
+	{Prefix: netip.MustParsePrefix("2002::/16"), Name: "6to4", Reference: "RFC3056"},
+	{Prefix: netip.MustParsePrefix("2620:4f:8000::/48"), Name: "Direct Delegation AS112 Service", Reference: "RFC7534", Global: true},
+	{Prefix: netip.MustParsePrefix("3fff::/20"), Name: "Documentation", Reference: "RFC9637"},
+	{Prefix: netip.MustParsePrefix("5f00::/16"), Name: "Segment Routing (SRv6) SIDs", Reference: "RFC9602"},
+	{Prefix: netip.MustParsePrefix("fc00::/7"), Name: "Unique-Local", Reference: "RFC4193"},
+	{Prefix: netip.MustParsePrefix("fe80::/10"), Name: "Link-Local Unicast", Reference: "RFC4291"},
+	{Prefix: netip.MustParsePrefix("ff00::/8"), Name: "Multicast", Reference: "RFC4291"},
+}
+
+// SpecialPurposeRegistry classifies addresses against the IANA special-purpose
+// registries, falling back to a loadable list of bogon prefixes such as
+// unallocated address space
+type SpecialPurposeRegistry struct {
+	blocks *prefixtrie.Trie[SpecialPurposeBlock]
+	bogons atomic.Pointer[prefixtrie.Trie[SpecialPurposeBlock]]
+}
+
+func NewSpecialPurposeRegistry() *SpecialPurposeRegistry {
+	registry := &SpecialPurposeRegistry{blocks: prefixtrie.New[SpecialPurposeBlock]()}
+	for _, block := range specialPurposeBlocks {
+		registry.blocks.Insert(block.Prefix, block)
+	}
+
+	return registry
+}
+
+// SetBogons replaces the loaded bogon list
+func (r *SpecialPurposeRegistry) SetBogons(prefixes []netip.Prefix, reference string) {
+	bogons := prefixtrie.New[SpecialPurposeBlock]()
+	for _, prefix := range prefixes {
+		bogons.Insert(prefix, SpecialPurposeBlock{
+			Prefix:    prefix.Masked(),
+			Name:      "Bogon",
+			Reference: reference,
+		})
+	}
+
+	r.bogons.Store(bogons)
+}
+
+// BogonCount returns the number of loaded bogon prefixes
+func (r *SpecialPurposeRegistry) BogonCount() int {
+	bogons := r.bogons.Load()
+	if bogons == nil {
+		return 0
+	}
+
+	return bogons.Len()
+}
+
+// Lookup returns the most specific special-purpose block containing the
+// address, if any
+func (r *SpecialPurposeRegistry) Lookup(addr netip.Addr) (*SpecialPurposeBlock, bool) {
+	addr = addr.Unmap()
+	return r.LookupPrefix(netip.PrefixFrom(addr, addr.BitLen()))
+}
+
+// LookupPrefix returns the most specific special-purpose block equal to or
+// containing the prefix, if any
+func (r *SpecialPurposeRegistry) LookupPrefix(prefix netip.Prefix) (*SpecialPurposeBlock, bool) {
+	if block, ok := mostSpecificBlock(r.blocks, prefix); ok {
+		return block, true
+	}
+
+	if bogons := r.bogons.Load(); bogons != nil {
+		return mostSpecificBlock(bogons, prefix)
+	}
+
+	return nil, false
+}
+
+func mostSpecificBlock(trie *prefixtrie.Trie[SpecialPurposeBlock], prefix netip.Prefix) (*SpecialPurposeBlock, bool) {
+	matches := trie.Covering(prefix)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	block := matches[len(matches)-1].Values[0]
+	return &block, true
+}
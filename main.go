@@ -7,10 +7,13 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/0x4c6565/lee.io/content"
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
 	"github.com/0x4c6565/lee.io/pkg/blog"
 	"github.com/0x4c6565/lee.io/pkg/connection"
 	"github.com/0x4c6565/lee.io/pkg/server"
 	"github.com/0x4c6565/lee.io/pkg/tool"
+	"github.com/0x4c6565/lee.io/static"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -42,32 +45,80 @@ func main() {
 
 	serverOpts := server.ServerOptions{
 		Initialise: config.Initialise,
+		CORS: server.CORSOptions{
+			AllowedOrigins:   config.CORS.AllowedOrigins,
+			AllowedMethods:   config.CORS.AllowedMethods,
+			AllowedHeaders:   config.CORS.AllowedHeaders,
+			MaxAge:           config.CORS.MaxAge,
+			AllowCredentials: config.CORS.AllowCredentials,
+		},
 	}
 
 	connFactory := connection.NewMySQLConnectionFactory(config.DB.Host, config.DB.Port, config.DB.User, config.DB.Password, config.DB.DB)
 
-	b, err := blog.New("./content/blog")
+	b, err := newBlog(config.Blog.Path)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to initialise blog")
 	}
 
-	server := server.NewServer(serverOpts).WithStatic("./static/lee.io").WithBlog(b).WithTools(
-		tool.NewWhois(),
+	staticFS, err := static.LeeIO()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to initialise static content")
+	}
+
+	whois := tool.NewWhois()
+	specialPurpose := util.NewSpecialPurposeRegistry()
+	rpki := tool.NewRPKI(config.RPKI.Source)
+
+	var cloudSources []tool.CloudSource
+	for _, source := range config.Cloud.Sources {
+		cloudSources = append(cloudSources, tool.CloudSource{Format: source.Format, Location: source.Location})
+	}
+	cloud := tool.NewCloud(cloudSources)
+
+	var reputationLists []tool.ReputationList
+	for _, list := range config.Reputation.Lists {
+		reputationLists = append(reputationLists, tool.ReputationList{Name: list.Name, Description: list.Description, Location: list.Location})
+	}
+
+	var dnsblZones []tool.DNSBLZone
+	for _, zone := range config.DNSBL.Zones {
+		dnsblZones = append(dnsblZones, tool.DNSBLZone{Zone: zone.Zone, Type: zone.Type})
+	}
+
+	mac := tool.NewMAC(connFactory)
+	bgp := tool.NewBGP(connFactory).WithRetention(config.BGP.Retention).WithMRTSources(config.BGP.MRTSources).WithRPKI(rpki).WithCloud(cloud).WithSpecialPurpose(specialPurpose)
+
+	server := server.NewServer(serverOpts).WithStaticFS(staticFS).WithStatic(config.Static.Path).WithBlog(b).WithTools(
+		whois,
+		tool.NewRDAP(tool.RDAPBootstrap{DNS: config.RDAP.Bootstrap.DNS, IPv4: config.RDAP.Bootstrap.IPv4, IPv6: config.RDAP.Bootstrap.IPv6, ASN: config.RDAP.Bootstrap.ASN}).WithSpecialPurpose(specialPurpose),
 		tool.NewIP(),
 		tool.NewPort(),
 		tool.NewSelfSigned(),
 		tool.NewKeypair(),
-		tool.NewSubnet(),
-		tool.NewMAC(connFactory),
-		tool.NewBGP(connFactory),
+		tool.NewSubnet().WithSpecialPurpose(specialPurpose),
+		tool.NewMACChanges(mac),
+		mac,
+		tool.NewBGPDiff(bgp),
+		tool.NewBGPHistory(bgp),
+		tool.NewBGPPrefixList(bgp),
+		tool.NewBGPCountry(bgp),
+		tool.NewIRR(bgp, config.IRR.Sources),
+		bgp,
+		tool.NewASN(bgp, whois),
+		rpki,
 		tool.NewUUID(),
-		tool.NewGeoIP(tool.NewGeoIP2FileSystemReader(config.GeoIP.DatabasePath)),
+		tool.NewGeoIP(tool.NewGeoIP2FileSystemReader(config.GeoIP.DatabasePath)).WithSpecialPurpose(specialPurpose).WithCloud(cloud),
 		tool.NewPassword(),
 		tool.NewSSLDecode(),
 		tool.NewEUI64(),
 		tool.NewSSL(),
 		tool.NewProjectName(),
-		tool.NewRDNS(),
+		tool.NewRDNS().WithSpecialPurpose(specialPurpose),
+		tool.NewBogon(specialPurpose, config.Bogon.Sources),
+		cloud,
+		tool.NewReputation(reputationLists),
+		tool.NewDNSBL(dnsblZones, config.DNSBL.Resolvers, config.DNSBL.Timeout).WithSpecialPurpose(specialPurpose),
 	)
 
 	err = server.Start(ctx)
@@ -77,3 +128,16 @@ func main() {
 
 	log.Info().Msg("lee.io shutdown")
 }
+
+func newBlog(path string) (*blog.Blog, error) {
+	if path != "" {
+		return blog.New(path)
+	}
+
+	blogFS, err := content.Blog()
+	if err != nil {
+		return nil, err
+	}
+
+	return blog.NewFS(blogFS)
+}
@@ -29,12 +29,16 @@ type Post struct {
 }
 
 type Blog struct {
-	contentPath string
-	indexTmpl   *template.Template
-	postTmpl    *template.Template
+	content   fs.FS
+	indexTmpl *template.Template
+	postTmpl  *template.Template
 }
 
 func New(contentPath string) (*Blog, error) {
+	return NewFS(os.DirFS(contentPath))
+}
+
+func NewFS(content fs.FS) (*Blog, error) {
 	indexTmpl, err := template.ParseFS(templateFS, "templates/index.html")
 	if err != nil {
 		return nil, err
@@ -44,9 +48,9 @@ func New(contentPath string) (*Blog, error) {
 		return nil, err
 	}
 	return &Blog{
-		contentPath: contentPath,
-		indexTmpl:   indexTmpl,
-		postTmpl:    postTmpl,
+		content:   content,
+		indexTmpl: indexTmpl,
+		postTmpl:  postTmpl,
 	}, nil
 }
 
@@ -60,7 +64,7 @@ func (b *Blog) Register(r *mux.Router) {
 }
 
 func (b *Blog) loadPosts() ([]Post, error) {
-	entries, err := os.ReadDir(b.contentPath)
+	entries, err := fs.ReadDir(b.content, ".")
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return nil, nil
@@ -74,7 +78,7 @@ func (b *Blog) loadPosts() ([]Post, error) {
 			continue
 		}
 
-		data, err := os.ReadFile(filepath.Join(b.contentPath, entry.Name()))
+		data, err := fs.ReadFile(b.content, entry.Name())
 		if err != nil {
 			log.Error().Err(err).Str("file", entry.Name()).Msg("failed to read blog post")
 			continue
@@ -99,8 +103,7 @@ func (b *Blog) loadPosts() ([]Post, error) {
 
 func (b *Blog) loadPost(slug string) (*Post, error) {
 	// slug is pre-validated to contain only [a-zA-Z0-9-], no path traversal possible
-	path := filepath.Join(b.contentPath, slug+".md")
-	data, err := os.ReadFile(path)
+	data, err := fs.ReadFile(b.content, slug+".md")
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return nil, nil
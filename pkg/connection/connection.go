@@ -7,16 +7,35 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-type Connection interface {
+type Querier interface {
 	Exec(query string, args ...any) (sql.Result, error)
 	Select(dest interface{}, query string, args ...interface{}) error
 	Get(dest interface{}, query string, args ...interface{}) error
 }
 
+type Connection interface {
+	Querier
+	Begin() (Transaction, error)
+}
+
+type Transaction interface {
+	Querier
+	Commit() error
+	Rollback() error
+}
+
 type ConnectionFactory interface {
 	New() (Connection, error)
 }
 
+type MySQLConnection struct {
+	*sqlx.DB
+}
+
+func (c *MySQLConnection) Begin() (Transaction, error) {
+	return c.DB.Beginx()
+}
+
 type MySQLConnectionFactory struct {
 	Host     string
 	Port     int
@@ -36,5 +55,10 @@ func NewMySQLConnectionFactory(host string, port int, username string, password
 }
 
 func (f *MySQLConnectionFactory) New() (Connection, error) {
-	return sqlx.Connect("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", f.Username, f.Password, f.Host, f.Port, f.Database))
+	db, err := sqlx.Connect("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", f.Username, f.Password, f.Host, f.Port, f.Database))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLConnection{DB: db}, nil
 }
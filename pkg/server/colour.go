@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0x4c6565/lee.io/pkg/tool"
+)
+
+var colourUserAgents = []string{"curl", "wget", "httpie"}
+
+// colourEnabled determines whether ANSI colour should be used for text
+// responses. Explicit query parameters (?color=0, ?nocolor) take precedence,
+// followed by terminal client user agents
+func colourEnabled(r *http.Request) bool {
+	query := r.URL.Query()
+	for _, key := range []string{"nocolor", "no_color", "nocolour", "no_colour"} {
+		if query.Has(key) {
+			return false
+		}
+	}
+
+	for _, key := range []string{"color", "colour"} {
+		if query.Has(key) {
+			switch strings.ToLower(query.Get(key)) {
+			case "", "1", "true", "yes", "on":
+				return true
+			default:
+				return false
+			}
+		}
+	}
+
+	for _, userAgent := range r.Header.Values("User-agent") {
+		userAgent = strings.ToLower(userAgent)
+		for _, colourUserAgent := range colourUserAgents {
+			if strings.Contains(userAgent, colourUserAgent) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func textData(data tool.ToolResponseData, colour bool) string {
+	if colourData, ok := data.(tool.ToolResponseDataColour); ok && colour {
+		return colourData.ColourString()
+	}
+
+	return data.String()
+}
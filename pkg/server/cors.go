@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var origin string = http.CanonicalHeaderKey("Origin")
+var accessControlRequestMethod string = http.CanonicalHeaderKey("Access-Control-Request-Method")
+var accessControlRequestHeaders string = http.CanonicalHeaderKey("Access-Control-Request-Headers")
+
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	MaxAge           int
+	AllowCredentials bool
+}
+
+type cors struct {
+	opts CORSOptions
+}
+
+// CORS handles cross-origin requests, answering preflight requests directly so
+// they never reach tools. No headers are set when no origins are configured
+func CORS(opts CORSOptions, h http.Handler) http.Handler {
+	if len(opts.AllowedOrigins) == 0 {
+		return h
+	}
+
+	c := &cors{opts: opts}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(origin) == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get(accessControlRequestMethod) != "" {
+			c.handlePreflight(w, r)
+			return
+		}
+
+		c.handleActual(w, r)
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func (c *cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	headers.Add("Vary", origin)
+	headers.Add("Vary", accessControlRequestMethod)
+	headers.Add("Vary", accessControlRequestHeaders)
+
+	requestOrigin := r.Header.Get(origin)
+	if !c.originAllowed(requestOrigin) || !c.methodAllowed(r.Header.Get(accessControlRequestMethod)) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	requestHeaders := c.parseHeaderList(r.Header.Get(accessControlRequestHeaders))
+	if !c.headersAllowed(requestHeaders) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.setOrigin(w, requestOrigin)
+	headers.Set("Access-Control-Allow-Methods", strings.Join(c.opts.AllowedMethods, ", "))
+	if len(requestHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if c.opts.MaxAge > 0 {
+		headers.Set("Access-Control-Max-Age", strconv.Itoa(c.opts.MaxAge))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) handleActual(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", origin)
+
+	requestOrigin := r.Header.Get(origin)
+	if !c.originAllowed(requestOrigin) {
+		return
+	}
+
+	c.setOrigin(w, requestOrigin)
+}
+
+func (c *cors) setOrigin(w http.ResponseWriter, requestOrigin string) {
+	// Wildcard origins cannot be used alongside credentials, so the request
+	// origin is reflected instead
+	if c.allowAllOrigins() && !c.opts.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", requestOrigin)
+	}
+
+	if c.opts.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowAllOrigins() bool {
+	for _, allowed := range c.opts.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *cors) originAllowed(requestOrigin string) bool {
+	requestOrigin = strings.ToLower(requestOrigin)
+	for _, allowed := range c.opts.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == requestOrigin {
+			return true
+		}
+
+		// Support subdomain wildcards, e.g. https://*.lee.io
+		if i := strings.Index(allowed, "*."); i != -1 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if strings.HasPrefix(requestOrigin, prefix) && strings.HasSuffix(requestOrigin, suffix) && len(requestOrigin) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *cors) methodAllowed(method string) bool {
+	for _, allowed := range c.opts.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *cors) headersAllowed(requestHeaders []string) bool {
+	for _, requestHeader := range requestHeaders {
+		found := false
+		for _, allowed := range c.opts.AllowedHeaders {
+			if allowed == "*" || http.CanonicalHeaderKey(allowed) == requestHeader {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *cors) parseHeaderList(headerList string) []string {
+	var headers []string
+	for _, header := range strings.Split(headerList, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	return headers
+}
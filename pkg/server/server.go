@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"io/fs"
 	"net/http"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 
 type ServerOptions struct {
 	Initialise bool
+	CORS       CORSOptions
 }
 
 type Server struct {
@@ -22,6 +24,7 @@ type Server struct {
 	opts       ServerOptions
 	blog       *blog.Blog
 	staticPath string
+	staticFS   fs.FS
 }
 
 func NewServer(opts ServerOptions) *Server {
@@ -45,6 +48,11 @@ func (s *Server) WithStatic(path string) *Server {
 	return s
 }
 
+func (s *Server) WithStaticFS(fsys fs.FS) *Server {
+	s.staticFS = fsys
+	return s
+}
+
 func (s *Server) Start(ctx context.Context) error {
 	log.Info().Msg("Starting server")
 
@@ -59,6 +67,10 @@ func (s *Server) Start(ctx context.Context) error {
 			r.HandleFunc(path, newHandler(t).handle)
 		}
 
+		if v, ok := t.(tool.ToolStart); ok {
+			v.Start()
+		}
+
 		if v, ok := t.(tool.ToolCron); ok {
 			spec := v.Cron()
 			if s.opts.Initialise {
@@ -73,10 +85,20 @@ func (s *Server) Start(ctx context.Context) error {
 		s.blog.Register(r)
 	}
 
-	r.PathPrefix("/").Handler(http.FileServer(http.Dir(s.staticPath)))
+	// A static directory takes precedence over the embedded filesystem,
+	// allowing changes to be seen without rebuilding during development
+	if s.staticPath != "" || s.staticFS == nil {
+		r.PathPrefix("/").Handler(http.FileServer(http.Dir(s.staticPath)))
+	} else {
+		static, err := newStaticHandler(s.staticFS)
+		if err != nil {
+			return err
+		}
+		r.PathPrefix("/").Handler(static)
+	}
 
 	go c.Run()
-	server := &http.Server{Addr: ":8080", Handler: ProxyHeaders(r)}
+	server := &http.Server{Addr: ":8080", Handler: ProxyHeaders(CORS(s.opts.CORS, r))}
 
 	var err error
 	go func() {
@@ -114,13 +136,25 @@ func (h *handler) handle(rw http.ResponseWriter, r *http.Request) {
 	accept := r.Header.Values("Accept")
 	userAgent := r.Header.Values("User-agent")
 
-	response, err := h.tool.Handle(r)
-
 	acceptHeader := ""
 	if len(accept) > 0 {
 		acceptHeader = accept[0]
 	}
 
+	if format, ok := streamFormat(r, acceptHeader); ok {
+		if streamer, ok := h.tool.(tool.ToolStream); ok {
+			h.handleStream(rw, r, streamer, format)
+			return
+		}
+
+		if format == streamFormatSSE {
+			h.handleStream(rw, r, responseStream{tool: h.tool}, format)
+			return
+		}
+	}
+
+	response, err := h.tool.Handle(r)
+
 	switch acceptHeader {
 	case "application/json":
 		if err != nil {
@@ -128,11 +162,19 @@ func (h *handler) handle(rw http.ResponseWriter, r *http.Request) {
 		} else {
 			jsonResponse(rw, 200, response.Data)
 		}
+	case "application/x-ndjson":
+		if err != nil {
+			ndjsonResponse(rw, 500, []interface{}{jsonErrorResponse{Error: err.Error()}})
+		} else if list, ok := response.Data.(tool.ToolResponseDataList); ok {
+			ndjsonResponse(rw, 200, list.Items())
+		} else {
+			ndjsonResponse(rw, 200, []interface{}{response.Data})
+		}
 	default:
 		if err != nil {
 			plainResponse(rw, 500, err.Error())
 		} else {
-			plainResponse(rw, 200, response.Data.String())
+			plainResponse(rw, 200, textData(response.Data, colourEnabled(r)))
 		}
 	}
 
@@ -155,6 +197,15 @@ func jsonResponse(rw http.ResponseWriter, code int, data interface{}) {
 	rw.Write(j)
 }
 
+func ndjsonResponse(rw http.ResponseWriter, code int, items []interface{}) {
+	rw.Header().Add("Content-type", "application/x-ndjson")
+	rw.WriteHeader(code)
+	for _, item := range items {
+		j, _ := json.Marshal(item)
+		rw.Write(append(j, '\n'))
+	}
+}
+
 func plainResponse(rw http.ResponseWriter, code int, data string) {
 	rw.Header().Add("Content-type", "text/plain;charset=UTF-8")
 	rw.WriteHeader(code)
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const STATIC_GZIP_MIN_SIZE = 1024
+
+var staticImmutablePrefixes = []string{"/assets/fontawesome/"}
+
+var staticCompressibleTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/json",
+	"image/svg+xml",
+	"font/ttf",
+}
+
+type staticEncoding struct {
+	name    string
+	content []byte
+	etag    string
+}
+
+type staticFile struct {
+	contentType string
+	content     []byte
+	etag        string
+	encodings   []staticEncoding
+}
+
+// staticHandler serves files from an in-memory copy of a filesystem, such as
+// the embedded site. Files are hashed for strong ETags, and served with
+// precompressed variants where present (e.g. file.css.br, file.css.gz),
+// preferring brotli, with gzip variants generated for compressible files
+// otherwise
+type staticHandler struct {
+	files map[string]*staticFile
+}
+
+func newStaticHandler(fsys fs.FS) (*staticHandler, error) {
+	h := &staticHandler{files: make(map[string]*staticFile)}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".br") || strings.HasSuffix(p, ".gz") {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		file := &staticFile{
+			contentType: mime.TypeByExtension(path.Ext(p)),
+			content:     content,
+			etag:        staticETag(content, ""),
+		}
+		if file.contentType == "" {
+			file.contentType = http.DetectContentType(content)
+		}
+
+		if br, err := fs.ReadFile(fsys, p+".br"); err == nil {
+			file.encodings = append(file.encodings, staticEncoding{name: "br", content: br, etag: staticETag(content, "br")})
+		}
+
+		if gz, err := fs.ReadFile(fsys, p+".gz"); err == nil {
+			file.encodings = append(file.encodings, staticEncoding{name: "gzip", content: gz, etag: staticETag(content, "gzip")})
+		} else if len(content) >= STATIC_GZIP_MIN_SIZE && staticCompressible(file.contentType) {
+			gz, err := staticGzip(content)
+			if err != nil {
+				return err
+			}
+			file.encodings = append(file.encodings, staticEncoding{name: "gzip", content: gz, etag: staticETag(content, "gzip")})
+		}
+
+		h.files["/"+p] = file
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+
+	file, ok := h.files[name]
+	if !ok {
+		file, ok = h.files[path.Join(name, "index.html")]
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	headers := w.Header()
+	headers.Set("Content-Type", file.contentType)
+	headers.Set("Cache-Control", staticCacheControl(name))
+
+	content := file.content
+	etag := file.etag
+	if len(file.encodings) > 0 {
+		headers.Add("Vary", "Accept-Encoding")
+		for _, encoding := range file.encodings {
+			if staticAcceptsEncoding(r, encoding.name) {
+				headers.Set("Content-Encoding", encoding.name)
+				content = encoding.content
+				etag = encoding.etag
+				break
+			}
+		}
+	}
+	headers.Set("ETag", etag)
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+func staticETag(content []byte, encoding string) string {
+	sum := sha256.Sum256(content)
+	tag := hex.EncodeToString(sum[:16])
+	if encoding != "" {
+		tag = tag + "-" + encoding
+	}
+
+	return `"` + tag + `"`
+}
+
+func staticCacheControl(name string) string {
+	for _, prefix := range staticImmutablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return "public, max-age=31536000, immutable"
+		}
+	}
+
+	return "public, no-cache"
+}
+
+func staticCompressible(contentType string) bool {
+	for _, compressibleType := range staticCompressibleTypes {
+		if strings.HasPrefix(contentType, compressibleType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func staticGzip(content []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gz, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := gz.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func staticAcceptsEncoding(r *http.Request, encoding string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, accepted := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+				continue
+			}
+
+			// Explicitly refused encodings, e.g. gzip;q=0
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/0x4c6565/lee.io/pkg/tool"
+)
+
+const (
+	streamFormatNDJSON = "application/x-ndjson"
+	streamFormatSSE    = "text/event-stream"
+	streamFormatText   = "text/plain"
+)
+
+type streamWriter struct {
+	rw      http.ResponseWriter
+	rc      *http.ResponseController
+	format  string
+	colour  bool
+	started bool
+}
+
+func newStreamWriter(rw http.ResponseWriter, format string, colour bool) *streamWriter {
+	return &streamWriter{
+		rw:     rw,
+		rc:     http.NewResponseController(rw),
+		format: format,
+		colour: colour,
+	}
+}
+
+func (s *streamWriter) start(code int) {
+	if s.started {
+		return
+	}
+
+	switch s.format {
+	case streamFormatNDJSON:
+		s.rw.Header().Add("Content-type", "application/x-ndjson")
+	case streamFormatSSE:
+		s.rw.Header().Add("Content-type", "text/event-stream")
+		s.rw.Header().Add("Cache-Control", "no-cache")
+	default:
+		s.rw.Header().Add("Content-type", "text/plain;charset=UTF-8")
+	}
+	s.rw.Header().Add("X-Accel-Buffering", "no")
+	s.rw.WriteHeader(code)
+	s.started = true
+}
+
+func (s *streamWriter) write(event string, data interface{}) error {
+	var out []byte
+	switch s.format {
+	case streamFormatNDJSON:
+		j, _ := json.Marshal(data)
+		out = append(j, '\n')
+	case streamFormatSSE:
+		j, _ := json.Marshal(data)
+		out = []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, j))
+	default:
+		switch v := data.(type) {
+		case tool.ToolResponseData:
+			out = []byte(textData(v, s.colour) + "\n")
+		case jsonErrorResponse:
+			out = []byte(v.Error + "\n")
+		}
+	}
+
+	if _, err := s.rw.Write(out); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+func (s *streamWriter) emit(data tool.ToolResponseData) error {
+	s.start(200)
+	return s.write("result", data)
+}
+
+func (s *streamWriter) fail(err error) {
+	s.start(500)
+	s.write("error", jsonErrorResponse{Error: err.Error()})
+}
+
+func (s *streamWriter) done() {
+	s.start(200)
+	if s.format == streamFormatSSE {
+		s.write("done", struct{}{})
+	}
+}
+
+// responseStream adapts a non-streaming tool, emitting its response as a
+// single item
+type responseStream struct {
+	tool tool.Tool
+}
+
+func (s responseStream) HandleStream(r *http.Request, emit tool.ToolStreamFunc) error {
+	response, err := s.tool.Handle(r)
+	if err != nil {
+		return err
+	}
+
+	return emit(response.Data)
+}
+
+func streamFormat(r *http.Request, accept string) (string, bool) {
+	switch accept {
+	case streamFormatNDJSON, streamFormatSSE:
+		return accept, true
+	}
+
+	if r.URL.Query().Has("stream") {
+		return streamFormatText, true
+	}
+
+	return "", false
+}
+
+func (h *handler) handleStream(rw http.ResponseWriter, r *http.Request, streamer tool.ToolStream, format string) {
+	w := newStreamWriter(rw, format, format == streamFormatText && colourEnabled(r))
+
+	err := streamer.HandleStream(r, w.emit)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	w.done()
+}
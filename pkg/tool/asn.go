@@ -0,0 +1,336 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const ASN_LARGEST_PREFIXES = 5
+
+const (
+	ASN_CLASSIFICATION_PUBLIC        = "public"
+	ASN_CLASSIFICATION_PRIVATE       = "private"
+	ASN_CLASSIFICATION_RESERVED      = "reserved"
+	ASN_CLASSIFICATION_DOCUMENTATION = "documentation"
+)
+
+// asnWhoisFields maps RIR whois attributes to summary labels, in display order
+var asnWhoisFields = []struct {
+	Label      string
+	Attributes []string
+}{
+	{Label: "AS Name", Attributes: []string{"as-name", "asname", "aut-num-name"}},
+	{Label: "Organisation", Attributes: []string{"orgname", "org-name", "owner", "descr"}},
+	{Label: "Country", Attributes: []string{"country"}},
+	{Label: "Abuse Contact", Attributes: []string{"orgabuseemail", "abuse-mailbox", "abuse-c"}},
+	{Label: "Created", Attributes: []string{"regdate", "created"}},
+	{Label: "Updated", Attributes: []string{"updated", "last-modified", "changed"}},
+	{Label: "Source", Attributes: []string{"source"}},
+}
+
+type ASN struct {
+	bgp   *BGP
+	whois *Whois
+}
+
+func NewASN(bgp *BGP, whois *Whois) *ASN {
+	return &ASN{
+		bgp:   bgp,
+		whois: whois,
+	}
+}
+
+func (a *ASN) Paths() []string {
+	return []string{"/asn/{asn}"}
+}
+
+func (a *ASN) Method() string {
+	return "GET"
+}
+
+func (a *ASN) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	asn, err := parseASN(vars["asn"])
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := a.bgp.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ASNResponseData{
+		ASN:         asn,
+		Owner:       "Unknown",
+		CountryCode: "Unknown",
+		Size:        "16-bit",
+	}
+	if asn > math.MaxUint16 {
+		response.Size = "32-bit"
+	}
+	response.Classification, response.Reference = classifyASN(asn)
+
+	// Until an import has run, fall back to the details held against the
+	// ASN's routes
+	if detail, ok := a.bgp.getASNDetails()[int(asn)]; ok {
+		response.Owner = detail.Owner
+		response.CountryCode = detail.CountryCode
+	} else if routes := index.asns[asn]; len(routes) > 0 {
+		response.Owner = index.routes[routes[0]].Owner
+		response.CountryCode = index.routes[routes[0]].CountryCode
+	}
+
+	var ipv4Prefixes, ipv6Prefixes []netip.Prefix
+	for _, r := range index.asns[asn] {
+		prefix, err := netip.ParsePrefix(index.routes[r].Route)
+		if err != nil {
+			continue
+		}
+
+		if prefix.Addr().Is4() {
+			ipv4Prefixes = append(ipv4Prefixes, prefix.Masked())
+		} else {
+			ipv6Prefixes = append(ipv6Prefixes, prefix.Masked())
+		}
+	}
+
+	response.IPv4Prefixes = len(ipv4Prefixes)
+	response.IPv6Prefixes = len(ipv6Prefixes)
+	response.IPv4Slash24s = asnAddressSpace(ipv4Prefixes, 24)
+	response.IPv6Slash48s = asnAddressSpace(ipv6Prefixes, 48)
+	response.LargestIPv4Prefixes = asnLargestPrefixes(ipv4Prefixes)
+	response.LargestIPv6Prefixes = asnLargestPrefixes(ipv6Prefixes)
+
+	response.Whois, err = a.whoisSummary(asn)
+	if err != nil {
+		log.Error().Err(err).Uint32("asn", asn).Msg("Failed to retrieve ASN whois summary")
+	}
+
+	return NewToolResponse(response), nil
+}
+
+// parseASN parses an ASN in asplain (13335, AS13335) or asdot (1.10) notation
+func parseASN(query string) (uint32, error) {
+	query = strings.TrimSpace(query)
+	if len(query) > 2 && strings.EqualFold(query[:2], "AS") {
+		query = query[2:]
+	}
+
+	if high, low, ok := strings.Cut(query, "."); ok {
+		h, herr := strconv.ParseUint(high, 10, 16)
+		l, lerr := strconv.ParseUint(low, 10, 16)
+		if herr != nil || lerr != nil {
+			return 0, errors.New("invalid ASN")
+		}
+
+		return uint32(h<<16 | l), nil
+	}
+
+	asn, err := strconv.ParseUint(query, 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid ASN")
+	}
+
+	return uint32(asn), nil
+}
+
+// classifyASN returns the classification of the ASN per the IANA special
+// purpose AS numbers registry, along with the defining RFC where applicable
+func classifyASN(asn uint32) (string, string) {
+	switch {
+	case asn == 0:
+		return ASN_CLASSIFICATION_RESERVED, "RFC7607"
+	case asn == 23456:
+		return ASN_CLASSIFICATION_RESERVED, "RFC6793"
+	case asn >= 64496 && asn <= 64511:
+		return ASN_CLASSIFICATION_DOCUMENTATION, "RFC5398"
+	case asn >= 64512 && asn <= 65534:
+		return ASN_CLASSIFICATION_PRIVATE, "RFC6996"
+	case asn == 65535:
+		return ASN_CLASSIFICATION_RESERVED, "RFC7300"
+	case asn >= 65536 && asn <= 65551:
+		return ASN_CLASSIFICATION_DOCUMENTATION, "RFC5398"
+	case asn >= 65552 && asn <= 131071:
+		return ASN_CLASSIFICATION_RESERVED, "IANA"
+	case asn >= 4200000000 && asn <= 4294967294:
+		return ASN_CLASSIFICATION_PRIVATE, "RFC6996"
+	case asn == 4294967295:
+		return ASN_CLASSIFICATION_RESERVED, "RFC7300"
+	}
+
+	return ASN_CLASSIFICATION_PUBLIC, ""
+}
+
+// asnAddressSpace returns the address space covered by the prefixes as a
+// number of prefixes of length bits. Prefixes covered by another announced
+// prefix are only counted once
+func asnAddressSpace(prefixes []netip.Prefix, bits int) float64 {
+	sorted := make([]netip.Prefix, len(prefixes))
+	copy(sorted, prefixes)
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	var total float64
+	var last netip.Prefix
+	for _, prefix := range sorted {
+		if last.IsValid() && last.Overlaps(prefix) {
+			continue
+		}
+		last = prefix
+		total += math.Pow(2, float64(bits-prefix.Bits()))
+	}
+
+	return total
+}
+
+func formatSlashCount(count float64) string {
+	return strconv.FormatFloat(count, 'f', -1, 64)
+}
+
+func asnLargestPrefixes(prefixes []netip.Prefix) []string {
+	sorted := make([]netip.Prefix, len(prefixes))
+	copy(sorted, prefixes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	if len(sorted) > ASN_LARGEST_PREFIXES {
+		sorted = sorted[:ASN_LARGEST_PREFIXES]
+	}
+
+	largest := []string{}
+	for _, prefix := range sorted {
+		largest = append(largest, prefix.String())
+	}
+
+	return largest
+}
+
+// whoisSummary retrieves the whois record for the ASN from the authoritative
+// RIR and extracts the key fields
+func (a *ASN) whoisSummary(asn uint32) ([]ASNWhoisField, error) {
+	var record []byte
+	err := a.whois.lookup(fmt.Sprintf("AS%d", asn), func(server string, response []byte) error {
+		record = response
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(record))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		if _, exists := attributes[key]; !exists {
+			attributes[key] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var fields []ASNWhoisField
+	for _, field := range asnWhoisFields {
+		for _, attribute := range field.Attributes {
+			if value, ok := attributes[attribute]; ok {
+				fields = append(fields, ASNWhoisField{Field: field.Label, Value: value})
+				break
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+type ASNWhoisField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type ASNResponseData struct {
+	ASN                 uint32          `json:"asn"`
+	Owner               string          `json:"owner"`
+	CountryCode         string          `json:"country_code"`
+	Size                string          `json:"size"`
+	Classification      string          `json:"classification"`
+	Reference           string          `json:"reference,omitempty"`
+	IPv4Prefixes        int             `json:"ipv4_prefixes"`
+	IPv6Prefixes        int             `json:"ipv6_prefixes"`
+	IPv4Slash24s        float64         `json:"ipv4_slash24s"`
+	IPv6Slash48s        float64         `json:"ipv6_slash48s"`
+	LargestIPv4Prefixes []string        `json:"largest_ipv4_prefixes"`
+	LargestIPv6Prefixes []string        `json:"largest_ipv6_prefixes"`
+	Whois               []ASNWhoisField `json:"whois"`
+}
+
+func (r *ASNResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *ASNResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *ASNResponseData) render(colour bool) string {
+	classification := r.Classification
+	if r.Reference != "" {
+		classification = fmt.Sprintf("%s (%s)", r.Classification, r.Reference)
+	}
+
+	pairs := []keyValue{
+		{Key: "ASN", Value: fmt.Sprintf("AS%d", r.ASN)},
+		{Key: "Owner", Value: r.Owner},
+		{Key: "Country", Value: r.CountryCode},
+		{Key: "Size", Value: r.Size},
+		{Key: "Classification", Value: classification},
+		{Key: "IPv4 Prefixes", Value: strconv.Itoa(r.IPv4Prefixes)},
+		{Key: "IPv6 Prefixes", Value: strconv.Itoa(r.IPv6Prefixes)},
+		{Key: "IPv4 Space", Value: formatSlashCount(r.IPv4Slash24s) + " /24s"},
+		{Key: "IPv6 Space", Value: formatSlashCount(r.IPv6Slash48s) + " /48s"},
+		{Key: "Largest IPv4", Value: strings.Join(r.LargestIPv4Prefixes, ", ")},
+		{Key: "Largest IPv6", Value: strings.Join(r.LargestIPv6Prefixes, ", ")},
+	}
+
+	output := renderKeyValues(16, colour, pairs...)
+
+	if len(r.Whois) > 0 {
+		var whoisPairs []keyValue
+		for _, field := range r.Whois {
+			whoisPairs = append(whoisPairs, keyValue{Key: field.Field, Value: field.Value})
+		}
+
+		output = output + "\n\n" + colourise("> Whois", ANSI_BOLD, colour) + "\n" + renderKeyValues(16, colour, whoisPairs...)
+	}
+
+	return output
+}
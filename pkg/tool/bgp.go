@@ -1,30 +1,49 @@
 package tool
 
 import (
-	"bufio"
-	"bytes"
 	"database/sql"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
-	"regexp"
+	"net/netip"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/0x4c6565/lee.io/internal/pkg/util"
 	"github.com/0x4c6565/lee.io/pkg/connection"
-	ierr "github.com/0x4c6565/lee.io/pkg/error"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/hashicorp/go-sockaddr"
-	"github.com/olekukonko/tablewriter"
 	"github.com/rs/zerolog/log"
 )
 
 const BGP_IPV4_RAW_TABLE_URL = "https://thyme.apnic.net/current/data-raw-table"
 const BGP_IPV6_RAW_TABLE_URL = "https://thyme.apnic.net/.combined/ipv6-raw-table"
 const BGP_USED_AUTONUMS_URL = "https://thyme.apnic.net/current/data-used-autnums"
+const BGP_INSERT_BATCH_SIZE = 1000
+const BGP_MIN_ROUTE_RATIO = 0.5
+const BGP_DEFAULT_RETENTION = 7
+const BGP_INDEX_RETRY_INTERVAL = time.Minute
+
+// bgpMigrations bring installs predating the current schema.sql up to date, and
+// are safe to run on every start
+var bgpMigrations = []string{
+	"ALTER TABLE bgp_route " +
+		"ADD COLUMN IF NOT EXISTS `as_path` text NOT NULL," +
+		"ADD COLUMN IF NOT EXISTS `upstreams` text NOT NULL," +
+		"ADD COLUMN IF NOT EXISTS `peer_count` int(11) NOT NULL DEFAULT 0," +
+		"ADD COLUMN IF NOT EXISTS `visibility` double NOT NULL DEFAULT 0",
+	"ALTER TABLE bgp_route " +
+		"ADD KEY IF NOT EXISTS `bgp_route_version_idx` (`version`)," +
+		"ADD KEY IF NOT EXISTS `bgp_route_route_idx` (`route`(64))",
+	"CREATE TABLE IF NOT EXISTS `bgp_snapshot` (" +
+		"`version` int(11) NOT NULL PRIMARY KEY," +
+		"`created_at` datetime NOT NULL," +
+		"`route_count` int(11) NOT NULL" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+}
 
 type BGPNotFoundError struct {
 	msg string
@@ -44,96 +63,87 @@ type BGPRouteVersion struct {
 	Version int `db:"version"`
 }
 
+type BGPSnapshot struct {
+	Version    int       `db:"version" json:"version"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	RouteCount int       `db:"route_count" json:"route_count"`
+}
+
 type BGPRoute struct {
-	ID          string `db:"id"`
-	Version     int    `db:"version"`
-	IPVersion   int    `db:"ip_version"`
-	Route       string `db:"route"`
-	ASNNumber   uint32 `db:"asn_number"`
-	Owner       string `db:"owner"`
-	CountryCode string `db:"country_code"`
-	IPv4Start   uint32 `db:"ipv4_start"`
-	IPv4End     uint32 `db:"ipv4_end"`
-	IPv6Start   string `db:"ipv6_start"`
-	IPv6End     string `db:"ipv6_end"`
+	ID          string  `db:"id"`
+	Version     int     `db:"version"`
+	IPVersion   int     `db:"ip_version"`
+	Route       string  `db:"route"`
+	ASNNumber   uint32  `db:"asn_number"`
+	Owner       string  `db:"owner"`
+	CountryCode string  `db:"country_code"`
+	IPv4Start   uint32  `db:"ipv4_start"`
+	IPv4End     uint32  `db:"ipv4_end"`
+	IPv6Start   string  `db:"ipv6_start"`
+	IPv6End     string  `db:"ipv6_end"`
+	ASPath      string  `db:"as_path"`
+	Upstreams   string  `db:"upstreams"`
+	PeerCount   int     `db:"peer_count"`
+	Visibility  float64 `db:"visibility"`
 }
 
 type BGPRouteRepository struct {
-	conn connection.Connection
+	conn connection.Querier
 }
 
-func NewBGPRouteRepository(conn connection.Connection) *BGPRouteRepository {
+func NewBGPRouteRepository(conn connection.Querier) *BGPRouteRepository {
 	return &BGPRouteRepository{
 		conn: conn,
 	}
 }
 
-func (s *BGPRouteRepository) Insert(route *BGPRoute) error {
-	id, err := uuid.NewRandom()
-	if err != nil {
-		return err
+func (s *BGPRouteRepository) Migrate() error {
+	for _, migration := range bgpMigrations {
+		if _, err := s.conn.Exec(migration); err != nil {
+			return err
+		}
 	}
 
-	_, err = s.conn.Exec("INSERT INTO bgp_route (`id`,`version`,`ip_version`,`route`,`asn_number`,`owner`,`country_code`,`ipv4_start`,`ipv4_end`,`ipv6_start`,`ipv6_end`) VALUES (?,?,?,?,?,?,?,?,?,?,?)", id.String(), route.Version, route.IPVersion, route.Route, route.ASNNumber, route.Owner, route.CountryCode, route.IPv4Start, route.IPv4End, route.IPv6Start, route.IPv6End)
-	return err
+	return nil
 }
 
-func (s *BGPRouteRepository) GetByASN(asn int) ([]BGPRoute, error) {
-	version, err := s.GetVersion()
-	if err != nil {
-		return nil, err
-	}
-
-	p := []BGPRoute{}
-	err = s.conn.Select(&p, "SELECT * FROM bgp_route WHERE version = ? AND asn_number = ?", version, asn)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
-	}
-
-	return p, nil
+func (s *BGPRouteRepository) Insert(route *BGPRoute) error {
+	return s.InsertBatch([]BGPRoute{*route})
 }
 
-func (s *BGPRouteRepository) GetByIPv4(ip net.IP) ([]BGPRoute, error) {
-	version, err := s.GetVersion()
-	if err != nil {
-		return nil, err
-	}
+// InsertBatch inserts routes using multi-row inserts of up to
+// BGP_INSERT_BATCH_SIZE rows
+func (s *BGPRouteRepository) InsertBatch(routes []BGPRoute) error {
+	for start := 0; start < len(routes); start += BGP_INSERT_BATCH_SIZE {
+		end := start + BGP_INSERT_BATCH_SIZE
+		if end > len(routes) {
+			end = len(routes)
+		}
 
-	p := []BGPRoute{}
-	ipInt := util.IPv4ToUInt(ip)
-	err = s.conn.Select(&p, "SELECT * FROM bgp_route WHERE version = ? AND ip_version = 4 AND ipv4_start <= ? AND ipv4_end >= ?", version, ipInt, ipInt)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
-	}
+		var placeholders []string
+		var args []interface{}
+		for _, route := range routes[start:end] {
+			id, err := uuid.NewRandom()
+			if err != nil {
+				return err
+			}
 
-	return p, nil
-}
-
-func (s *BGPRouteRepository) GetByIPv6(ip net.IP) ([]BGPRoute, error) {
-	version, err := s.GetVersion()
-	if err != nil {
-		return nil, err
-	}
+			placeholders = append(placeholders, "(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)")
+			args = append(args, id.String(), route.Version, route.IPVersion, route.Route, route.ASNNumber, route.Owner, route.CountryCode, route.IPv4Start, route.IPv4End, route.IPv6Start, route.IPv6End, route.ASPath, route.Upstreams, route.PeerCount, route.Visibility)
+		}
 
-	p := []BGPRoute{}
-	err = s.conn.Select(&p, "SELECT * FROM bgp_route WHERE version = ? AND ip_version = 6 AND INET6_ATON(ipv6_start) <= INET6_ATON(?) AND  INET6_ATON(ipv6_end) >= INET6_ATON(?)", version, ip.String(), ip.String())
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
+		_, err := s.conn.Exec("INSERT INTO bgp_route (`id`,`version`,`ip_version`,`route`,`asn_number`,`owner`,`country_code`,`ipv4_start`,`ipv4_end`,`ipv6_start`,`ipv6_end`,`as_path`,`upstreams`,`peer_count`,`visibility`) VALUES "+strings.Join(placeholders, ","), args...)
+		if err != nil {
+			return err
+		}
 	}
 
-	return p, nil
+	return nil
 }
 
-func (s *BGPRouteRepository) GetByOwner(owner string) ([]BGPRoute, error) {
-	version, err := s.GetVersion()
-	if err != nil {
-		return nil, err
-	}
-
-	ownerLike := "%" + owner + "%"
-
+func (s *BGPRouteRepository) GetAll(version int) ([]BGPRoute, error) {
 	p := []BGPRoute{}
-	err = s.conn.Select(&p, "SELECT * FROM bgp_route WHERE version = ? AND owner LIKE ?", version, ownerLike)
+	err := s.conn.Select(&p, "SELECT * FROM bgp_route WHERE version = ?", version)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -173,23 +183,155 @@ func (s *BGPRouteRepository) SetVersion(version int) error {
 	return err
 }
 
+func (s *BGPRouteRepository) CountByVersion(version int) (int, error) {
+	var count int
+	err := s.conn.Get(&count, "SELECT COUNT(*) FROM bgp_route WHERE version = ?", version)
+	return count, err
+}
+
 func (s *BGPRouteRepository) RemoveRouteVersion(version int) error {
 	_, err := s.conn.Exec("DELETE FROM bgp_route WHERE version = ?", version)
 	return err
 }
 
+// RemoveVersionsBefore removes routes and snapshots for all versions older
+// than the one provided
+func (s *BGPRouteRepository) RemoveVersionsBefore(version int) error {
+	_, err := s.conn.Exec("DELETE FROM bgp_route WHERE version < ?", version)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Exec("DELETE FROM bgp_snapshot WHERE version < ?", version)
+	return err
+}
+
+func (s *BGPRouteRepository) CreateSnapshot(snapshot *BGPSnapshot) error {
+	_, err := s.conn.Exec("INSERT INTO bgp_snapshot (`version`,`created_at`,`route_count`) VALUES (?,?,?)", snapshot.Version, snapshot.CreatedAt, snapshot.RouteCount)
+	return err
+}
+
+func (s *BGPRouteRepository) RemoveSnapshot(version int) error {
+	_, err := s.conn.Exec("DELETE FROM bgp_snapshot WHERE version = ?", version)
+	return err
+}
+
+// GetSnapshots returns retained snapshots, newest first
+func (s *BGPRouteRepository) GetSnapshots() ([]BGPSnapshot, error) {
+	p := []BGPSnapshot{}
+	err := s.conn.Select(&p, "SELECT * FROM bgp_snapshot ORDER BY version DESC")
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (s *BGPRouteRepository) GetSnapshot(version int) (*BGPSnapshot, error) {
+	p := BGPSnapshot{}
+	err := s.conn.Get(&p, "SELECT * FROM bgp_snapshot WHERE version = ?", version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// GetRouteHistory returns all retained versions of the route, oldest first
+func (s *BGPRouteRepository) GetRouteHistory(route string) ([]BGPRoute, error) {
+	p := []BGPRoute{}
+	err := s.conn.Select(&p, "SELECT * FROM bgp_route WHERE route = ? ORDER BY version", route)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return p, nil
+}
+
 type BGP struct {
-	connFactory connection.ConnectionFactory
+	connFactory    connection.ConnectionFactory
+	index          atomic.Pointer[bgpIndex]
+	indexMutex     sync.Mutex
+	asnDetails     atomic.Pointer[map[int]asnDetails]
+	retention      int
+	mrtSources     []string
+	rpki           *RPKI
+	cloud          *Cloud
+	specialPurpose *util.SpecialPurposeRegistry
 }
 
 func NewBGP(connFactory connection.ConnectionFactory) *BGP {
-	return &BGP{connFactory: connFactory}
+	return &BGP{connFactory: connFactory, retention: BGP_DEFAULT_RETENTION}
+}
+
+// WithRetention sets the number of table versions retained for history and
+// diffs, including the current version
+func (b *BGP) WithRetention(retention int) *BGP {
+	if retention < 1 {
+		retention = 1
+	}
+	b.retention = retention
+	return b
+}
+
+// WithMRTSources imports routes from MRT TABLE_DUMP_V2 RIB dumps rather than
+// the APNIC tables, adding AS paths, upstreams and visibility
+func (b *BGP) WithMRTSources(sources []string) *BGP {
+	b.mrtSources = sources
+	return b
+}
+
+// WithRPKI annotates results with their RPKI route origin validation state
+func (b *BGP) WithRPKI(rpki *RPKI) *BGP {
+	b.rpki = rpki
+	return b
+}
+
+// WithCloud tags results with the cloud or CDN provider ranges they fall in
+func (b *BGP) WithCloud(cloud *Cloud) *BGP {
+	b.cloud = cloud
+	return b
+}
+
+// WithSpecialPurpose short-circuits lookups for addresses which aren't
+// globally reachable, and flags routes within special-purpose or bogon space
+func (b *BGP) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *BGP {
+	b.specialPurpose = registry
+	return b
+}
+
+// Start migrates the schema before any import or lookup uses it, then builds
+// the route index in the background
+func (b *BGP) Start() {
+	conn, err := b.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("BGP: Failed to initialise database, skipping migration")
+	} else if err := NewBGPRouteRepository(conn).Migrate(); err != nil {
+		log.Error().Err(err).Msg("BGP: Migration failed")
+	}
+
+	go b.loadInitialIndex()
+}
+
+// loadInitialIndex builds the route index from the database, retrying until
+// it loads or an import builds it first
+func (b *BGP) loadInitialIndex() {
+	for b.index.Load() == nil {
+		err := b.reloadIndex()
+		if err == nil {
+			return
+		}
+
+		log.Error().Err(err).Msg("BGP: Failed to load index, retrying")
+		time.Sleep(BGP_INDEX_RETRY_INTERVAL)
+	}
 }
 
 func (b *BGP) Paths() []string {
 	return []string{
 		"/bgp",
 		"/bgp/{query}",
+		"/bgp/{query}/{length:[0-9]+}",
 	}
 }
 
@@ -198,264 +340,377 @@ func (w *BGP) Method() string {
 }
 
 func (b *BGP) Handle(r *http.Request) (*ToolResponse, error) {
+	opts, err := newBGPQueryOptions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Method == http.MethodPost {
+		queries, err := parseBulkQueries(r)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := b.getIndex()
+		if err != nil {
+			return nil, err
+		}
+
+		return NewToolResponse(runBulk(queries, func(query string) (ToolResponseData, error) {
+			return b.lookup(index, query, opts)
+		})), nil
+	}
+
 	vars := mux.Vars(r)
 	query, ok := vars["query"]
 	if !ok {
 		query = util.GetSourceIPAddress(r)
 	}
 
-	var routes []BGPRoute
-	var queryErr error
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
 
-	conn, err := b.connFactory.New()
+	index, err := b.getIndex()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to initialise database")
-		return nil, ierr.InternalServerError
+		return nil, err
+	}
+
+	response, err := b.lookup(index, query, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	bgpRouteRepository := NewBGPRouteRepository(conn)
+	return NewToolResponse(response), nil
+}
 
-	if asn, err := strconv.Atoi(strings.ToUpper(strings.TrimPrefix(query, "AS"))); err == nil {
-		routes, queryErr = bgpRouteRepository.GetByASN(asn)
-	} else if ipAddress := net.ParseIP(query); ipAddress != nil {
-		if ip4 := ipAddress.To4(); ip4 != nil {
-			routes, queryErr = bgpRouteRepository.GetByIPv4(ipAddress)
-		} else {
-			routes, queryErr = bgpRouteRepository.GetByIPv6(ipAddress)
+func (b *BGP) HandleStream(r *http.Request, emit ToolStreamFunc) error {
+	if r.Method != http.MethodPost {
+		response, err := b.Handle(r)
+		if err != nil {
+			return err
 		}
-	} else {
-		routes, queryErr = bgpRouteRepository.GetByOwner(query)
+
+		return emit(response.Data)
 	}
 
-	if queryErr != nil {
-		log.Error().Err(queryErr).Send()
-		return nil, errors.New("failed to query BGP info")
+	queries, err := parseBulkQueries(r)
+	if err != nil {
+		return err
 	}
 
-	var response BGPResponseData
-	for _, route := range routes {
-		response = append(response, BGPResponseDataItem{
-			Route:       route.Route,
-			ASNNumber:   route.ASNNumber,
-			Owner:       route.Owner,
-			CountryCode: route.CountryCode,
-		})
+	opts, err := newBGPQueryOptions(r)
+	if err != nil {
+		return err
 	}
 
-	return NewToolResponse(&response), nil
-}
+	index, err := b.getIndex()
+	if err != nil {
+		return err
+	}
 
-func (b *BGP) Cron() CronSpec {
-	return CronSpec{Cron: "0 2 * * *", Func: b.cronWork}
+	return streamBulk(queries, func(query string) (ToolResponseData, error) {
+		return b.lookup(index, query, opts)
+	}, emit)
 }
 
-func (b *BGP) cronWork() {
-	log.Info().Msg("BGP: Starting cron")
+type bgpQueryOptions struct {
+	Exact  bool
+	Less   bool
+	More   bool
+	Search searchOptions
+}
 
-	conn, err := b.connFactory.New()
+// newBGPQueryOptions parses prefix filters and owner search options from the
+// request. Without any prefix filters, exact, less and more specific routes
+// are all returned. For owner searches, ?exact matches the owner exactly
+func newBGPQueryOptions(r *http.Request) (bgpQueryOptions, error) {
+	search, err := newSearchOptions(r)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to initialise database")
-		return
+		return bgpQueryOptions{}, err
 	}
-	bgpRouteRepository := NewBGPRouteRepository(conn)
 
-	currentVersion, err := bgpRouteRepository.GetVersion()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to query current version")
-		return
+	query := r.URL.Query()
+	opts := bgpQueryOptions{
+		Exact:  query.Has("exact"),
+		Less:   query.Has("less"),
+		More:   query.Has("more"),
+		Search: search,
 	}
 
-	newVersion := currentVersion + 1
-
-	asnDetailsMap, err := b.getASNDetailsMap()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to retrieve ASN details")
-		return
+	if !opts.Exact && !opts.Less && !opts.More {
+		opts.Exact, opts.Less, opts.More = true, true, true
 	}
 
-	err = b.processIPv4Routes(bgpRouteRepository, newVersion, asnDetailsMap)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to process IPv4 routes")
-		return
+	return opts, nil
+}
+
+func (b *BGP) lookup(index *bgpIndex, query string, opts bgpQueryOptions) (ToolResponseData, error) {
+	var response BGPResponseData
+
+	if asn, err := strconv.ParseUint(strings.ToUpper(strings.TrimPrefix(query, "AS")), 10, 32); err == nil {
+		response = index.getByASN(uint32(asn))
+	} else if ipAddress, err := netip.ParseAddr(query); err == nil {
+		if err := specialPurposeError(b.specialPurpose, ipAddress); err != nil {
+			return nil, err
+		}
+		response = index.getByIP(ipAddress)
+	} else if strings.Contains(query, "/") {
+		prefix, err := netip.ParsePrefix(query)
+		if err != nil {
+			return nil, errors.New("invalid prefix")
+		}
+		if err := specialPurposePrefixError(b.specialPurpose, prefix.Masked()); err != nil {
+			return nil, err
+		}
+		response = index.getByPrefix(prefix.Masked(), opts)
+	} else {
+		return index.getOwnerSummary(query, opts.Search), nil
 	}
 
-	err = b.processIPv6Routes(bgpRouteRepository, newVersion, asnDetailsMap)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to process IPv6 routes")
-		return
+	for i, item := range response {
+		prefix, err := netip.ParsePrefix(item.Route)
+		if err != nil {
+			continue
+		}
+
+		if b.rpki != nil {
+			response[i].RPKI, response[i].RPKIReason = b.rpki.validate(prefix.Masked(), item.ASNNumber)
+		}
+		if b.cloud != nil {
+			response[i].Cloud = b.cloud.annotate(prefix.Masked())
+		}
+		response[i].Bogon = specialPurposeAnnotation(b.specialPurpose, prefix.Masked())
 	}
 
-	err = bgpRouteRepository.SetVersion(newVersion)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to set new version")
-		return
+	return &response, nil
+}
+
+// getIndex returns the current in-memory route index, which is built at
+// startup and rebuilt by each import
+func (b *BGP) getIndex() (*bgpIndex, error) {
+	index := b.index.Load()
+	if index == nil {
+		return nil, errors.New("BGP data not yet loaded")
 	}
 
-	err = bgpRouteRepository.RemoveRouteVersion(currentVersion)
+	return index, nil
+}
+
+func (b *BGP) reloadIndex() error {
+	conn, err := b.connFactory.New()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to remove old version routes")
-		return
+		return fmt.Errorf("failed to initialise database: %w", err)
 	}
 
-	log.Info().Msg("BGP: Cron completed")
-}
+	b.indexMutex.Lock()
+	defer b.indexMutex.Unlock()
 
-type asnDetails struct {
-	Owner       string
-	CountryCode string
+	_, err = b.loadIndex(NewBGPRouteRepository(conn))
+	return err
 }
 
-func (b *BGP) getASNDetailsMap() (map[int]asnDetails, error) {
-	response, err := http.Get(BGP_USED_AUTONUMS_URL)
+func (b *BGP) loadIndex(bgpRouteRepository *BGPRouteRepository) (*bgpIndex, error) {
+	version, err := bgpRouteRepository.GetVersion()
 	if err != nil {
 		return nil, err
 	}
 
-	defer response.Body.Close()
+	routes, err := bgpRouteRepository.GetAll(version)
+	if err != nil {
+		return nil, err
+	}
 
-	result := make(map[int]asnDetails)
+	index := newBGPIndex(version, routes)
+	b.index.Store(index)
 
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		re := regexp.MustCompile(`\s*(\d+)\s+(.*),\s(\w+)`)
-		match := re.FindAllSubmatch(scanner.Bytes(), -1)
-		if match == nil {
-			continue
-		}
-		asnNumber, _ := strconv.Atoi(string(match[0][1]))
-		result[int(asnNumber)] = asnDetails{
-			Owner:       string(match[0][2]),
-			CountryCode: string(match[0][3]),
+	log.Debug().Int("version", version).Int("routes", len(routes)).Msg("Loaded BGP index")
+	return index, nil
+}
+
+type BGPResponseData []BGPResponseDataItem
+
+type BGPResponseDataItem struct {
+	Route        string   `json:"route"`
+	ASNNumber    uint32   `json:"asn_number"`
+	Owner        string   `json:"owner"`
+	CountryCode  string   `json:"country_code"`
+	MostSpecific bool     `json:"most_specific,omitempty"`
+	Relation     string   `json:"relation,omitempty"`
+	ASPath       []uint32 `json:"as_path,omitempty"`
+	Upstreams    []uint32 `json:"upstreams,omitempty"`
+	PeerCount    int      `json:"peer_count,omitempty"`
+	Visibility   float64  `json:"visibility,omitempty"`
+	RPKI         string   `json:"rpki,omitempty"`
+	RPKIReason   string   `json:"rpki_reason,omitempty"`
+	Cloud        string   `json:"cloud,omitempty"`
+	Bogon        string   `json:"bogon,omitempty"`
+}
+
+func (r *BGPResponseData) hasMostSpecific() bool {
+	for _, bgp := range *r {
+		if bgp.MostSpecific {
+			return true
 		}
 	}
 
-	return result, nil
+	return false
 }
 
-func (b *BGP) processIPv4Routes(bgpRouteRepository *BGPRouteRepository, version int, asnDetails map[int]asnDetails) error {
-	log.Debug().Msg("Processing IPv4 routes")
-	response, err := http.Get(BGP_IPV4_RAW_TABLE_URL)
-	if err != nil {
-		return nil
+func (r *BGPResponseData) hasRelation() bool {
+	for _, bgp := range *r {
+		if bgp.Relation != "" {
+			return true
+		}
 	}
 
-	defer response.Body.Close()
+	return false
+}
 
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		re := regexp.MustCompile(`([0-9.]+\/\d+)\s+(\d+)`)
-		match := re.FindAllSubmatch(scanner.Bytes(), -1)
-		if match == nil {
-			continue
+func (r *BGPResponseData) hasVisibility() bool {
+	for _, bgp := range *r {
+		if bgp.PeerCount > 0 {
+			return true
 		}
+	}
 
-		route := string(match[0][1])
-		asnNumber, _ := strconv.Atoi(string(match[0][2]))
-		asnOwner := "Unknown"
-		asnCountryCode := "Unknown"
+	return false
+}
 
-		if asnDetail, ok := asnDetails[asnNumber]; ok {
-			asnOwner = asnDetail.Owner
-			asnCountryCode = asnDetail.CountryCode
+func (r *BGPResponseData) hasRPKI() bool {
+	for _, bgp := range *r {
+		if bgp.RPKI != "" {
+			return true
 		}
+	}
 
-		parsedRoutePrefix, err := sockaddr.NewIPv4Addr(route)
-		if err != nil {
-			return fmt.Errorf("failed to parse route: %s", err.Error())
-		}
+	return false
+}
 
-		bgpRoute := &BGPRoute{
-			Version:     version,
-			IPVersion:   4,
-			Route:       route,
-			ASNNumber:   uint32(asnNumber),
-			Owner:       asnOwner,
-			CountryCode: asnCountryCode,
-			IPv4Start:   uint32(parsedRoutePrefix.NetworkAddress()),
-			IPv4End:     uint32(parsedRoutePrefix.BroadcastAddress()),
+func (r *BGPResponseData) hasCloud() bool {
+	for _, bgp := range *r {
+		if bgp.Cloud != "" {
+			return true
 		}
+	}
 
-		err = bgpRouteRepository.Insert(bgpRoute)
-		if err != nil {
-			return err
+	return false
+}
+
+func (r *BGPResponseData) hasBogon() bool {
+	for _, bgp := range *r {
+		if bgp.Bogon != "" {
+			return true
 		}
 	}
 
-	log.Debug().Msg("Finished processing IPv4 routes")
-	return nil
+	return false
 }
 
-func (b *BGP) processIPv6Routes(bgpRouteRepository *BGPRouteRepository, version int, asnDetails map[int]asnDetails) error {
-	log.Debug().Msg("Processing IPv6 routes")
-	response, err := http.Get(BGP_IPV6_RAW_TABLE_URL)
-	if err != nil {
-		return nil
+func (r *BGPResponseData) Header() []string {
+	header := []string{"route", "asn_number", "owner", "country_code"}
+	if r.hasMostSpecific() {
+		header = append(header, "most_specific")
+	}
+	if r.hasRelation() {
+		header = append(header, "relation")
+	}
+	if r.hasVisibility() {
+		header = append(header, "upstreams", "visibility")
+	}
+	if r.hasRPKI() {
+		header = append(header, "rpki")
+	}
+	if r.hasCloud() {
+		header = append(header, "cloud")
+	}
+	if r.hasBogon() {
+		header = append(header, "bogon")
 	}
 
-	defer response.Body.Close()
-
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		re := regexp.MustCompile(`([0-9a-f:]+\/\d+)\s+(\d+)`)
-		match := re.FindAllSubmatch(scanner.Bytes(), -1)
-		if match == nil {
-			continue
-		}
+	return header
+}
 
-		route := string(match[0][1])
-		asnNumber, _ := strconv.Atoi(string(match[0][2]))
-		asnOwner := "Unknown"
-		asnCountryCode := "Unknown"
+func (r *BGPResponseData) Rows() [][]string {
+	mostSpecific := r.hasMostSpecific()
+	relation := r.hasRelation()
+	visibility := r.hasVisibility()
+	rpki := r.hasRPKI()
+	cloud := r.hasCloud()
+	bogon := r.hasBogon()
 
-		if asnDetail, ok := asnDetails[asnNumber]; ok {
-			asnOwner = asnDetail.Owner
-			asnCountryCode = asnDetail.CountryCode
+	var rows [][]string
+	for _, bgp := range *r {
+		row := []string{bgp.Route, strconv.FormatUint(uint64(bgp.ASNNumber), 10), bgp.Owner, bgp.CountryCode}
+		if mostSpecific {
+			row = append(row, checkMark(bgp.MostSpecific))
 		}
-
-		parsedRoutePrefix, err := sockaddr.NewIPv6Addr(route)
-		if err != nil {
-			return fmt.Errorf("failed to parse route: %s", err.Error())
+		if relation {
+			row = append(row, bgp.Relation)
 		}
-
-		bgpRoute := &BGPRoute{
-			Version:     version,
-			IPVersion:   6,
-			Route:       route,
-			ASNNumber:   uint32(asnNumber),
-			Owner:       asnOwner,
-			CountryCode: asnCountryCode,
-			IPv6Start:   parsedRoutePrefix.FirstUsable().String(),
-			IPv6End:     parsedRoutePrefix.LastUsable().String(),
+		if visibility {
+			row = append(row, formatASPath(bgp.Upstreams), fmt.Sprintf("%.1f%% (%d peers)", bgp.Visibility, bgp.PeerCount))
 		}
-
-		err = bgpRouteRepository.Insert(bgpRoute)
-		if err != nil {
-			return err
+		if rpki {
+			row = append(row, formatRPKIState(bgp.RPKI, bgp.RPKIReason))
+		}
+		if cloud {
+			row = append(row, bgp.Cloud)
 		}
+		if bogon {
+			row = append(row, bgp.Bogon)
+		}
+		rows = append(rows, row)
 	}
 
-	log.Debug().Msg("Finished processing IPv6 routes")
-	return nil
+	return rows
 }
 
-type BGPResponseData []BGPResponseDataItem
+func (r *BGPResponseData) String() string {
+	return renderTableData(r, false)
+}
 
-type BGPResponseDataItem struct {
-	Route       string `json:"route"`
-	ASNNumber   uint32 `json:"asn_number"`
-	Owner       string `json:"owner"`
-	CountryCode string `json:"country_code"`
+func (r *BGPResponseData) ColourString() string {
+	return renderTableData(r, true)
 }
 
-func (r *BGPResponseData) String() string {
-	output := new(bytes.Buffer)
-	table := tablewriter.NewWriter(output)
-	table.Header([]string{"route", "asn_number", "owner", "country_code"})
+type BGPOwnerResponseDataItem struct {
+	ASNNumber    uint32 `json:"asn_number"`
+	Owner        string `json:"owner"`
+	CountryCode  string `json:"country_code"`
+	IPv4Prefixes int    `json:"ipv4_prefixes"`
+	IPv6Prefixes int    `json:"ipv6_prefixes"`
+	Score        int    `json:"score"`
+}
 
-	for _, bgp := range *r {
-		table.Append([]string{bgp.Route, strconv.FormatUint(uint64(bgp.ASNNumber), 10), bgp.Owner, bgp.CountryCode})
+type BGPOwnerResponseData struct {
+	SearchPage
+	Results []BGPOwnerResponseDataItem `json:"results"`
+}
+
+func (r *BGPOwnerResponseData) Header() []string {
+	return []string{"asn_number", "owner", "country_code", "ipv4_prefixes", "ipv6_prefixes"}
+}
+
+func (r *BGPOwnerResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, owner := range r.Results {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(owner.ASNNumber), 10),
+			owner.Owner,
+			owner.CountryCode,
+			strconv.Itoa(owner.IPv4Prefixes),
+			strconv.Itoa(owner.IPv6Prefixes),
+		})
 	}
-	table.Render()
 
-	return output.String()
+	return rows
+}
+
+func (r *BGPOwnerResponseData) String() string {
+	return renderTableData(r, false) + "\n" + r.footer()
+}
+
+func (r *BGPOwnerResponseData) ColourString() string {
+	return renderTableData(r, true) + "\n" + r.footer()
 }
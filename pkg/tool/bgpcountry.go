@@ -0,0 +1,259 @@
+package tool
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// bgpCountryStats is computed once per BGP index version, as aggregating the
+// full table is too expensive to perform per request
+type bgpCountryStats struct {
+	index     *bgpIndex
+	summary   []BGPCountrySummaryResponseDataItem
+	countries map[string][]BGPCountryResponseDataItem
+}
+
+type BGPCountry struct {
+	bgp        *BGP
+	stats      *bgpCountryStats
+	statsMutex sync.Mutex
+}
+
+func NewBGPCountry(bgp *BGP) *BGPCountry {
+	return &BGPCountry{bgp: bgp}
+}
+
+func (c *BGPCountry) Paths() []string {
+	return []string{
+		"/bgp/country",
+		"/bgp/country/{cc:[A-Za-z]{2}}",
+	}
+}
+
+func (c *BGPCountry) Method() string {
+	return "GET"
+}
+
+func (c *BGPCountry) Handle(r *http.Request) (*ToolResponse, error) {
+	stats, err := c.getStats()
+	if err != nil {
+		return nil, err
+	}
+
+	cc, ok := mux.Vars(r)["cc"]
+	if !ok {
+		response := BGPCountrySummaryResponseData(stats.summary)
+		return NewToolResponse(&response), nil
+	}
+
+	cc = strings.ToUpper(cc)
+	asns, ok := stats.countries[cc]
+	if !ok {
+		return nil, NewBGPNotFoundError(fmt.Sprintf("no routes found for country %s", cc))
+	}
+
+	return NewToolResponse(&BGPCountryResponseData{CountryCode: cc, ASNs: asns}), nil
+}
+
+func (c *BGPCountry) getStats() (*bgpCountryStats, error) {
+	index, err := c.bgp.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+
+	if c.stats == nil || c.stats.index != index {
+		c.stats = newBGPCountryStats(index)
+	}
+
+	return c.stats, nil
+}
+
+func newBGPCountryStats(index *bgpIndex) *bgpCountryStats {
+	type prefixes struct {
+		ipv4 []netip.Prefix
+		ipv6 []netip.Prefix
+	}
+
+	asnPrefixes := make(map[uint32]*prefixes)
+	countryPrefixes := make(map[string]*prefixes)
+	countryASNs := make(map[string][]uint32)
+	owners := make(map[uint32]string)
+
+	for _, route := range index.routes {
+		prefix, err := netip.ParsePrefix(route.Route)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+
+		if _, ok := asnPrefixes[route.ASNNumber]; !ok {
+			asnPrefixes[route.ASNNumber] = &prefixes{}
+			countryASNs[route.CountryCode] = append(countryASNs[route.CountryCode], route.ASNNumber)
+			owners[route.ASNNumber] = route.Owner
+		}
+		if _, ok := countryPrefixes[route.CountryCode]; !ok {
+			countryPrefixes[route.CountryCode] = &prefixes{}
+		}
+
+		if prefix.Addr().Is4() {
+			asnPrefixes[route.ASNNumber].ipv4 = append(asnPrefixes[route.ASNNumber].ipv4, prefix)
+			countryPrefixes[route.CountryCode].ipv4 = append(countryPrefixes[route.CountryCode].ipv4, prefix)
+		} else {
+			asnPrefixes[route.ASNNumber].ipv6 = append(asnPrefixes[route.ASNNumber].ipv6, prefix)
+			countryPrefixes[route.CountryCode].ipv6 = append(countryPrefixes[route.CountryCode].ipv6, prefix)
+		}
+	}
+
+	stats := &bgpCountryStats{
+		index:     index,
+		summary:   []BGPCountrySummaryResponseDataItem{},
+		countries: make(map[string][]BGPCountryResponseDataItem),
+	}
+
+	for cc, asns := range countryASNs {
+		var items []BGPCountryResponseDataItem
+		for _, asn := range asns {
+			p := asnPrefixes[asn]
+			items = append(items, BGPCountryResponseDataItem{
+				ASNNumber:    asn,
+				Owner:        owners[asn],
+				IPv4Prefixes: len(p.ipv4),
+				IPv6Prefixes: len(p.ipv6),
+				IPv4Slash24s: asnAddressSpace(p.ipv4, 24),
+				IPv6Slash48s: asnAddressSpace(p.ipv6, 48),
+			})
+		}
+
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].IPv4Slash24s != items[j].IPv4Slash24s {
+				return items[i].IPv4Slash24s > items[j].IPv4Slash24s
+			}
+			if items[i].IPv6Slash48s != items[j].IPv6Slash48s {
+				return items[i].IPv6Slash48s > items[j].IPv6Slash48s
+			}
+			return items[i].ASNNumber < items[j].ASNNumber
+		})
+		stats.countries[strings.ToUpper(cc)] = items
+
+		// Country totals are deduplicated across ASNs, so overlapping routes
+		// announced by different ASNs are only counted once
+		p := countryPrefixes[cc]
+		stats.summary = append(stats.summary, BGPCountrySummaryResponseDataItem{
+			CountryCode:  strings.ToUpper(cc),
+			ASNs:         len(asns),
+			IPv4Prefixes: len(p.ipv4),
+			IPv6Prefixes: len(p.ipv6),
+			IPv4Slash24s: asnAddressSpace(p.ipv4, 24),
+			IPv6Slash48s: asnAddressSpace(p.ipv6, 48),
+		})
+	}
+
+	sort.Slice(stats.summary, func(i, j int) bool {
+		if stats.summary[i].IPv4Slash24s != stats.summary[j].IPv4Slash24s {
+			return stats.summary[i].IPv4Slash24s > stats.summary[j].IPv4Slash24s
+		}
+		if stats.summary[i].IPv6Slash48s != stats.summary[j].IPv6Slash48s {
+			return stats.summary[i].IPv6Slash48s > stats.summary[j].IPv6Slash48s
+		}
+		return stats.summary[i].CountryCode < stats.summary[j].CountryCode
+	})
+
+	for i := range stats.summary {
+		stats.summary[i].Rank = i + 1
+	}
+
+	return stats
+}
+
+type BGPCountryResponseDataItem struct {
+	ASNNumber    uint32  `json:"asn_number"`
+	Owner        string  `json:"owner"`
+	IPv4Prefixes int     `json:"ipv4_prefixes"`
+	IPv6Prefixes int     `json:"ipv6_prefixes"`
+	IPv4Slash24s float64 `json:"ipv4_slash24s"`
+	IPv6Slash48s float64 `json:"ipv6_slash48s"`
+}
+
+type BGPCountryResponseData struct {
+	CountryCode string                       `json:"country_code"`
+	ASNs        []BGPCountryResponseDataItem `json:"asns"`
+}
+
+func (r *BGPCountryResponseData) Header() []string {
+	return []string{"asn_number", "owner", "ipv4_prefixes", "ipv6_prefixes", "ipv4_slash24s", "ipv6_slash48s"}
+}
+
+func (r *BGPCountryResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, asn := range r.ASNs {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(asn.ASNNumber), 10),
+			asn.Owner,
+			strconv.Itoa(asn.IPv4Prefixes),
+			strconv.Itoa(asn.IPv6Prefixes),
+			formatSlashCount(asn.IPv4Slash24s),
+			formatSlashCount(asn.IPv6Slash48s),
+		})
+	}
+
+	return rows
+}
+
+func (r *BGPCountryResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *BGPCountryResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
+
+type BGPCountrySummaryResponseDataItem struct {
+	Rank         int     `json:"rank"`
+	CountryCode  string  `json:"country_code"`
+	ASNs         int     `json:"asns"`
+	IPv4Prefixes int     `json:"ipv4_prefixes"`
+	IPv6Prefixes int     `json:"ipv6_prefixes"`
+	IPv4Slash24s float64 `json:"ipv4_slash24s"`
+	IPv6Slash48s float64 `json:"ipv6_slash48s"`
+}
+
+type BGPCountrySummaryResponseData []BGPCountrySummaryResponseDataItem
+
+func (r *BGPCountrySummaryResponseData) Header() []string {
+	return []string{"rank", "country_code", "asns", "ipv4_prefixes", "ipv6_prefixes", "ipv4_slash24s", "ipv6_slash48s"}
+}
+
+func (r *BGPCountrySummaryResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, country := range *r {
+		rows = append(rows, []string{
+			strconv.Itoa(country.Rank),
+			country.CountryCode,
+			strconv.Itoa(country.ASNs),
+			strconv.Itoa(country.IPv4Prefixes),
+			strconv.Itoa(country.IPv6Prefixes),
+			formatSlashCount(country.IPv4Slash24s),
+			formatSlashCount(country.IPv6Slash48s),
+		})
+	}
+
+	return rows
+}
+
+func (r *BGPCountrySummaryResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *BGPCountrySummaryResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
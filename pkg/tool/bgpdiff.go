@@ -0,0 +1,436 @@
+package tool
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ierr "github.com/0x4c6565/lee.io/pkg/error"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// BGP_DIFF_CACHE_SIZE is the number of most recent versions whose origins are
+// cached, which is enough for the default comparison of the latest two
+const BGP_DIFF_CACHE_SIZE = 2
+
+const (
+	BGP_CHANGE_ANNOUNCED      = "announced"
+	BGP_CHANGE_WITHDRAWN      = "withdrawn"
+	BGP_CHANGE_ORIGIN_CHANGED = "origin-changed"
+)
+
+// bgpOrigins maps each prefix in a table version to its origin ASNs
+type bgpOrigins map[netip.Prefix]bgpOrigin
+
+type bgpOrigin struct {
+	ASNs  []uint32
+	Owner string
+}
+
+func newBGPOrigins(routes []BGPRoute) bgpOrigins {
+	origins := make(bgpOrigins)
+	for _, route := range routes {
+		prefix, err := netip.ParsePrefix(route.Route)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+
+		origin := origins[prefix]
+		origin.ASNs = append(origin.ASNs, route.ASNNumber)
+		if origin.Owner == "" {
+			origin.Owner = route.Owner
+		}
+		origins[prefix] = origin
+	}
+
+	for prefix, origin := range origins {
+		sort.Slice(origin.ASNs, func(i, j int) bool { return origin.ASNs[i] < origin.ASNs[j] })
+		origins[prefix] = origin
+	}
+
+	return origins
+}
+
+// bgpOriginsLoad is an in progress load of a version's origins, shared by
+// concurrent requests for the same version
+type bgpOriginsLoad struct {
+	done    chan struct{}
+	origins bgpOrigins
+	err     error
+}
+
+type BGPDiff struct {
+	bgp        *BGP
+	cache      map[int]bgpOrigins
+	loads      map[int]*bgpOriginsLoad
+	cacheMutex sync.Mutex
+}
+
+func NewBGPDiff(bgp *BGP) *BGPDiff {
+	return &BGPDiff{
+		bgp:   bgp,
+		cache: make(map[int]bgpOrigins),
+		loads: make(map[int]*bgpOriginsLoad),
+	}
+}
+
+func (d *BGPDiff) Paths() []string {
+	return []string{
+		"/bgp/diff",
+		"/bgp/diff/{from:[0-9]+}/{to:[0-9]+}",
+	}
+}
+
+func (d *BGPDiff) Method() string {
+	return "GET"
+}
+
+func (d *BGPDiff) Handle(r *http.Request) (*ToolResponse, error) {
+	filter, err := newBGPDiffFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.bgp.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialise database")
+		return nil, ierr.InternalServerError
+	}
+	bgpRouteRepository := NewBGPRouteRepository(conn)
+
+	from, to, err := d.getSnapshots(bgpRouteRepository, mux.Vars(r))
+	if err != nil {
+		return nil, err
+	}
+
+	fromOrigins, err := d.getOrigins(bgpRouteRepository, from.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	toOrigins, err := d.getOrigins(bgpRouteRepository, to.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &BGPDiffResponseData{
+		From:    *from,
+		To:      *to,
+		Changes: diffBGPOrigins(fromOrigins, toOrigins, filter),
+	}
+
+	return NewToolResponse(response), nil
+}
+
+// getSnapshots returns the snapshots to compare, defaulting to the two most
+// recent when no versions are provided
+func (d *BGPDiff) getSnapshots(bgpRouteRepository *BGPRouteRepository, vars map[string]string) (*BGPSnapshot, *BGPSnapshot, error) {
+	if _, ok := vars["from"]; !ok {
+		snapshots, err := bgpRouteRepository.GetSnapshots()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve BGP snapshots")
+			return nil, nil, errors.New("failed to query BGP snapshots")
+		}
+
+		if len(snapshots) < 2 {
+			return nil, nil, errors.New("not enough BGP snapshots retained to compare")
+		}
+
+		return &snapshots[1], &snapshots[0], nil
+	}
+
+	from, err := d.getSnapshot(bgpRouteRepository, vars["from"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err := d.getSnapshot(bgpRouteRepository, vars["to"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return from, to, nil
+}
+
+func (d *BGPDiff) getSnapshot(bgpRouteRepository *BGPRouteRepository, v string) (*BGPSnapshot, error) {
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, errors.New("invalid version")
+	}
+
+	snapshot, err := bgpRouteRepository.GetSnapshot(version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NewBGPNotFoundError(fmt.Sprintf("version %d is not retained", version))
+		}
+
+		log.Error().Err(err).Msg("Failed to retrieve BGP snapshot")
+		return nil, errors.New("failed to query BGP snapshots")
+	}
+
+	return snapshot, nil
+}
+
+// getOrigins returns origins for the version, using the in-memory index for
+// the current version. Retained versions never change, so the most recent are
+// cached. Versions are loaded outside the cache lock, with concurrent requests
+// for the same version sharing a single load
+func (d *BGPDiff) getOrigins(bgpRouteRepository *BGPRouteRepository, version int) (bgpOrigins, error) {
+	index, err := d.bgp.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	d.cacheMutex.Lock()
+	if origins, ok := d.cache[version]; ok {
+		d.cacheMutex.Unlock()
+		return origins, nil
+	}
+
+	if load, ok := d.loads[version]; ok {
+		d.cacheMutex.Unlock()
+		<-load.done
+		return load.origins, load.err
+	}
+
+	load := &bgpOriginsLoad{done: make(chan struct{})}
+	d.loads[version] = load
+	d.cacheMutex.Unlock()
+
+	load.origins, load.err = d.loadOrigins(bgpRouteRepository, index, version)
+
+	d.cacheMutex.Lock()
+	delete(d.loads, version)
+	if load.err == nil {
+		d.cache[version] = load.origins
+		for len(d.cache) > BGP_DIFF_CACHE_SIZE {
+			oldest := -1
+			for v := range d.cache {
+				if oldest == -1 || v < oldest {
+					oldest = v
+				}
+			}
+			delete(d.cache, oldest)
+		}
+	}
+	d.cacheMutex.Unlock()
+	close(load.done)
+
+	return load.origins, load.err
+}
+
+func (d *BGPDiff) loadOrigins(bgpRouteRepository *BGPRouteRepository, index *bgpIndex, version int) (bgpOrigins, error) {
+	if index.version == version {
+		return newBGPOrigins(index.routes), nil
+	}
+
+	routes, err := bgpRouteRepository.GetAll(version)
+	if err != nil {
+		log.Error().Err(err).Int("version", version).Msg("Failed to retrieve BGP routes")
+		return nil, errors.New("failed to query BGP info")
+	}
+
+	return newBGPOrigins(routes), nil
+}
+
+type bgpDiffFilter struct {
+	ASN    *uint32
+	Prefix *netip.Prefix
+}
+
+func newBGPDiffFilter(r *http.Request) (bgpDiffFilter, error) {
+	var filter bgpDiffFilter
+	query := r.URL.Query()
+
+	if v := query.Get("asn"); v != "" {
+		asn, err := parseASN(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.ASN = &asn
+	}
+
+	if v := query.Get("prefix"); v != "" {
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return filter, errors.New("invalid prefix")
+		}
+		prefix = prefix.Masked()
+		filter.Prefix = &prefix
+	}
+
+	return filter, nil
+}
+
+func (f bgpDiffFilter) match(prefix netip.Prefix, origins ...bgpOrigin) bool {
+	if f.Prefix != nil && !f.Prefix.Overlaps(prefix) {
+		return false
+	}
+
+	if f.ASN == nil {
+		return true
+	}
+
+	for _, origin := range origins {
+		for _, asn := range origin.ASNs {
+			if asn == *f.ASN {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func diffBGPOrigins(from bgpOrigins, to bgpOrigins, filter bgpDiffFilter) []BGPDiffResponseDataItem {
+	type change struct {
+		prefix netip.Prefix
+		item   BGPDiffResponseDataItem
+	}
+	var changes []change
+
+	for prefix, toOrigin := range to {
+		fromOrigin, ok := from[prefix]
+		if !filter.match(prefix, fromOrigin, toOrigin) {
+			continue
+		}
+
+		if !ok {
+			changes = append(changes, change{prefix, BGPDiffResponseDataItem{
+				Prefix: prefix.String(),
+				Change: BGP_CHANGE_ANNOUNCED,
+				ToASNs: toOrigin.ASNs,
+				Owner:  toOrigin.Owner,
+			}})
+		} else if !equalASNs(fromOrigin.ASNs, toOrigin.ASNs) {
+			changes = append(changes, change{prefix, BGPDiffResponseDataItem{
+				Prefix:   prefix.String(),
+				Change:   BGP_CHANGE_ORIGIN_CHANGED,
+				FromASNs: fromOrigin.ASNs,
+				ToASNs:   toOrigin.ASNs,
+				Owner:    toOrigin.Owner,
+			}})
+		}
+	}
+
+	for prefix, fromOrigin := range from {
+		if _, ok := to[prefix]; ok || !filter.match(prefix, fromOrigin) {
+			continue
+		}
+
+		changes = append(changes, change{prefix, BGPDiffResponseDataItem{
+			Prefix:   prefix.String(),
+			Change:   BGP_CHANGE_WITHDRAWN,
+			FromASNs: fromOrigin.ASNs,
+			Owner:    fromOrigin.Owner,
+		}})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if c := changes[i].prefix.Addr().Compare(changes[j].prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return changes[i].prefix.Bits() < changes[j].prefix.Bits()
+	})
+
+	items := []BGPDiffResponseDataItem{}
+	for _, c := range changes {
+		items = append(items, c.item)
+	}
+
+	return items
+}
+
+func equalASNs(a []uint32, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatASNs(asns []uint32) string {
+	var s []string
+	for _, asn := range asns {
+		s = append(s, strconv.FormatUint(uint64(asn), 10))
+	}
+
+	return strings.Join(s, ",")
+}
+
+type BGPDiffResponseDataItem struct {
+	Prefix   string   `json:"prefix"`
+	Change   string   `json:"change"`
+	FromASNs []uint32 `json:"from_asns,omitempty"`
+	ToASNs   []uint32 `json:"to_asns,omitempty"`
+	Owner    string   `json:"owner"`
+}
+
+type BGPDiffResponseData struct {
+	From    BGPSnapshot               `json:"from"`
+	To      BGPSnapshot               `json:"to"`
+	Changes []BGPDiffResponseDataItem `json:"changes"`
+}
+
+func (r *BGPDiffResponseData) count(change string) int {
+	count := 0
+	for _, item := range r.Changes {
+		if item.Change == change {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r *BGPDiffResponseData) Header() []string {
+	return []string{"prefix", "change", "from_asn", "to_asn", "owner"}
+}
+
+func (r *BGPDiffResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, item := range r.Changes {
+		rows = append(rows, []string{item.Prefix, item.Change, formatASNs(item.FromASNs), formatASNs(item.ToASNs), item.Owner})
+	}
+
+	return rows
+}
+
+func (r *BGPDiffResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *BGPDiffResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *BGPDiffResponseData) render(colour bool) string {
+	summary := renderKeyValues(12, colour,
+		keyValue{Key: "From", Value: fmt.Sprintf("%d (%s)", r.From.Version, r.From.CreatedAt.Format(time.RFC3339))},
+		keyValue{Key: "To", Value: fmt.Sprintf("%d (%s)", r.To.Version, r.To.CreatedAt.Format(time.RFC3339))},
+		keyValue{Key: "Announced", Value: strconv.Itoa(r.count(BGP_CHANGE_ANNOUNCED))},
+		keyValue{Key: "Withdrawn", Value: strconv.Itoa(r.count(BGP_CHANGE_WITHDRAWN))},
+		keyValue{Key: "Changed", Value: strconv.Itoa(r.count(BGP_CHANGE_ORIGIN_CHANGED))},
+	)
+
+	if len(r.Changes) == 0 {
+		return summary
+	}
+
+	return summary + "\n\n" + renderTableData(r, colour)
+}
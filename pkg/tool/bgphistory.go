@@ -0,0 +1,151 @@
+package tool
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	ierr "github.com/0x4c6565/lee.io/pkg/error"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+type BGPHistory struct {
+	bgp *BGP
+}
+
+func NewBGPHistory(bgp *BGP) *BGPHistory {
+	return &BGPHistory{bgp: bgp}
+}
+
+func (h *BGPHistory) Paths() []string {
+	return []string{
+		"/bgp/history",
+		"/bgp/history/{prefix}/{length:[0-9]+}",
+	}
+}
+
+func (h *BGPHistory) Method() string {
+	return "GET"
+}
+
+func (h *BGPHistory) Handle(r *http.Request) (*ToolResponse, error) {
+	conn, err := h.bgp.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialise database")
+		return nil, ierr.InternalServerError
+	}
+	bgpRouteRepository := NewBGPRouteRepository(conn)
+
+	snapshots, err := bgpRouteRepository.GetSnapshots()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve BGP snapshots")
+		return nil, errors.New("failed to query BGP snapshots")
+	}
+
+	vars := mux.Vars(r)
+	if _, ok := vars["prefix"]; !ok {
+		response := BGPSnapshotResponseData(snapshots)
+		return NewToolResponse(&response), nil
+	}
+
+	prefix, err := netip.ParsePrefix(vars["prefix"] + "/" + vars["length"])
+	if err != nil {
+		return nil, errors.New("invalid prefix")
+	}
+	prefix = prefix.Masked()
+
+	routes, err := bgpRouteRepository.GetRouteHistory(prefix.String())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve BGP route history")
+		return nil, errors.New("failed to query BGP info")
+	}
+
+	origins := make(map[int]bgpOrigin)
+	for _, route := range routes {
+		origin := origins[route.Version]
+		origin.ASNs = append(origin.ASNs, route.ASNNumber)
+		if origin.Owner == "" {
+			origin.Owner = route.Owner
+		}
+		origins[route.Version] = origin
+	}
+
+	response := &BGPHistoryResponseData{
+		Prefix:  prefix.String(),
+		History: []BGPHistoryResponseDataItem{},
+	}
+
+	// Snapshots are newest first, whereas history reads oldest first
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		origin, announced := origins[snapshots[i].Version]
+		response.History = append(response.History, BGPHistoryResponseDataItem{
+			Version:   snapshots[i].Version,
+			CreatedAt: snapshots[i].CreatedAt,
+			Announced: announced,
+			ASNs:      origin.ASNs,
+			Owner:     origin.Owner,
+		})
+	}
+
+	return NewToolResponse(response), nil
+}
+
+type BGPSnapshotResponseData []BGPSnapshot
+
+func (r *BGPSnapshotResponseData) Header() []string {
+	return []string{"version", "created_at", "route_count"}
+}
+
+func (r *BGPSnapshotResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, snapshot := range *r {
+		rows = append(rows, []string{strconv.Itoa(snapshot.Version), snapshot.CreatedAt.Format(time.RFC3339), strconv.Itoa(snapshot.RouteCount)})
+	}
+
+	return rows
+}
+
+func (r *BGPSnapshotResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *BGPSnapshotResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
+
+type BGPHistoryResponseDataItem struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Announced bool      `json:"announced"`
+	ASNs      []uint32  `json:"asns,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+}
+
+type BGPHistoryResponseData struct {
+	Prefix  string                       `json:"prefix"`
+	History []BGPHistoryResponseDataItem `json:"history"`
+}
+
+func (r *BGPHistoryResponseData) Header() []string {
+	return []string{"version", "created_at", "announced", "asn_number", "owner"}
+}
+
+func (r *BGPHistoryResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, item := range r.History {
+		rows = append(rows, []string{strconv.Itoa(item.Version), item.CreatedAt.Format(time.RFC3339), checkMark(item.Announced), formatASNs(item.ASNs), item.Owner})
+	}
+
+	return rows
+}
+
+func (r *BGPHistoryResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *BGPHistoryResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
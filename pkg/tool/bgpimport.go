@@ -0,0 +1,323 @@
+package tool
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-sockaddr"
+	"github.com/rs/zerolog/log"
+)
+
+var bgpAutnumRegexp = regexp.MustCompile(`\s*(\d+)\s+(.*),\s(\w+)`)
+var bgpIPv4RouteRegexp = regexp.MustCompile(`([0-9.]+\/\d+)\s+(\d+)`)
+var bgpIPv6RouteRegexp = regexp.MustCompile(`([0-9a-f:]+\/\d+)\s+(\d+)`)
+
+func (b *BGP) Cron() CronSpec {
+	return CronSpec{Cron: "0 2 * * *", Func: b.cronWork}
+}
+
+func (b *BGP) cronWork() {
+	log.Info().Msg("BGP: Starting cron")
+
+	err := b.importRoutes()
+	if err != nil {
+		log.Error().Err(err).Msg("BGP: Import failed, keeping current routes")
+		return
+	}
+
+	log.Info().Msg("BGP: Cron completed")
+}
+
+// importRoutes retrieves and parses the route tables in full before writing
+// them in a single transaction alongside the version switch, so a failed
+// import never replaces the current table
+func (b *BGP) importRoutes() error {
+	asnDetailsMap, err := b.getASNDetailsMap()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve ASN details: %w", err)
+	}
+	b.asnDetails.Store(&asnDetailsMap)
+
+	routes, err := b.fetchRoutes(asnDetailsMap)
+	if err != nil {
+		return err
+	}
+
+	conn, err := b.connFactory.New()
+	if err != nil {
+		return fmt.Errorf("failed to initialise database: %w", err)
+	}
+	bgpRouteRepository := NewBGPRouteRepository(conn)
+
+	currentVersion, err := bgpRouteRepository.GetVersion()
+	if err != nil {
+		return fmt.Errorf("failed to query current version: %w", err)
+	}
+
+	currentCount, err := bgpRouteRepository.CountByVersion(currentVersion)
+	if err != nil {
+		return fmt.Errorf("failed to count current routes: %w", err)
+	}
+
+	if len(routes) == 0 || float64(len(routes)) < float64(currentCount)*BGP_MIN_ROUTE_RATIO {
+		return fmt.Errorf("refusing to switch to table with %d routes, current table has %d routes", len(routes), currentCount)
+	}
+
+	newVersion := currentVersion + 1
+	for i := range routes {
+		routes[i].Version = newVersion
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	txRouteRepository := NewBGPRouteRepository(tx)
+
+	err = b.writeRoutes(txRouteRepository, newVersion, routes)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit routes: %w", err)
+	}
+
+	log.Info().Int("version", newVersion).Int("routes", len(routes)).Msg("BGP: Switched to new route version")
+
+	err = b.removeExpiredVersions(bgpRouteRepository)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to remove expired BGP route versions")
+	}
+
+	b.indexMutex.Lock()
+	_, err = b.loadIndex(bgpRouteRepository)
+	b.indexMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to rebuild BGP index: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BGP) fetchRoutes(asnDetailsMap map[int]asnDetails) ([]BGPRoute, error) {
+	if len(b.mrtSources) > 0 {
+		routes, err := b.fetchMRTRoutes(asnDetailsMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process MRT routes: %w", err)
+		}
+
+		return routes, nil
+	}
+
+	ipv4Routes, err := b.fetchIPv4Routes(asnDetailsMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process IPv4 routes: %w", err)
+	}
+
+	ipv6Routes, err := b.fetchIPv6Routes(asnDetailsMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process IPv6 routes: %w", err)
+	}
+
+	return append(ipv4Routes, ipv6Routes...), nil
+}
+
+func (b *BGP) writeRoutes(bgpRouteRepository *BGPRouteRepository, version int, routes []BGPRoute) error {
+	// Clear out any rows left behind for this version by a previous run
+	err := bgpRouteRepository.RemoveRouteVersion(version)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale routes: %w", err)
+	}
+
+	err = bgpRouteRepository.InsertBatch(routes)
+	if err != nil {
+		return fmt.Errorf("failed to insert routes: %w", err)
+	}
+
+	err = bgpRouteRepository.RemoveSnapshot(version)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale snapshot: %w", err)
+	}
+
+	err = bgpRouteRepository.CreateSnapshot(&BGPSnapshot{
+		Version:    version,
+		CreatedAt:  time.Now().UTC(),
+		RouteCount: len(routes),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	err = bgpRouteRepository.SetVersion(version)
+	if err != nil {
+		return fmt.Errorf("failed to set new version: %w", err)
+	}
+
+	return nil
+}
+
+// removeExpiredVersions removes routes for versions outside of the retention
+// window, including any predating snapshot history
+func (b *BGP) removeExpiredVersions(bgpRouteRepository *BGPRouteRepository) error {
+	snapshots, err := bgpRouteRepository.GetSnapshots()
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) < b.retention {
+		return nil
+	}
+
+	return bgpRouteRepository.RemoveVersionsBefore(snapshots[b.retention-1].Version)
+}
+
+type asnDetails struct {
+	Owner       string
+	CountryCode string
+}
+
+// getASNDetails returns the ASN details retrieved by the last import, or nil
+// if no import has run since startup
+func (b *BGP) getASNDetails() map[int]asnDetails {
+	if details := b.asnDetails.Load(); details != nil {
+		return *details
+	}
+
+	return nil
+}
+
+func (b *BGP) getASNDetailsMap() (map[int]asnDetails, error) {
+	body, err := openSource(BGP_USED_AUTONUMS_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	result := make(map[int]asnDetails)
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		match := bgpAutnumRegexp.FindAllSubmatch(scanner.Bytes(), -1)
+		if match == nil {
+			continue
+		}
+		asnNumber, _ := strconv.Atoi(string(match[0][1]))
+		result[int(asnNumber)] = asnDetails{
+			Owner:       string(match[0][2]),
+			CountryCode: string(match[0][3]),
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *BGP) fetchIPv4Routes(asnDetails map[int]asnDetails) ([]BGPRoute, error) {
+	log.Debug().Msg("Processing IPv4 routes")
+	body, err := openSource(BGP_IPV4_RAW_TABLE_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	var routes []BGPRoute
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		match := bgpIPv4RouteRegexp.FindAllSubmatch(scanner.Bytes(), -1)
+		if match == nil {
+			continue
+		}
+
+		route := string(match[0][1])
+		asnNumber, _ := strconv.Atoi(string(match[0][2]))
+		asnOwner, asnCountryCode := lookupASNDetails(asnDetails, asnNumber)
+
+		parsedRoutePrefix, err := sockaddr.NewIPv4Addr(route)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse route: %s", err.Error())
+		}
+
+		routes = append(routes, BGPRoute{
+			IPVersion:   4,
+			Route:       route,
+			ASNNumber:   uint32(asnNumber),
+			Owner:       asnOwner,
+			CountryCode: asnCountryCode,
+			IPv4Start:   uint32(parsedRoutePrefix.NetworkAddress()),
+			IPv4End:     uint32(parsedRoutePrefix.BroadcastAddress()),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Int("routes", len(routes)).Msg("Finished processing IPv4 routes")
+	return routes, nil
+}
+
+func (b *BGP) fetchIPv6Routes(asnDetails map[int]asnDetails) ([]BGPRoute, error) {
+	log.Debug().Msg("Processing IPv6 routes")
+	body, err := openSource(BGP_IPV6_RAW_TABLE_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	var routes []BGPRoute
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		match := bgpIPv6RouteRegexp.FindAllSubmatch(scanner.Bytes(), -1)
+		if match == nil {
+			continue
+		}
+
+		route := string(match[0][1])
+		asnNumber, _ := strconv.Atoi(string(match[0][2]))
+		asnOwner, asnCountryCode := lookupASNDetails(asnDetails, asnNumber)
+
+		parsedRoutePrefix, err := sockaddr.NewIPv6Addr(route)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse route: %s", err.Error())
+		}
+
+		routes = append(routes, BGPRoute{
+			IPVersion:   6,
+			Route:       route,
+			ASNNumber:   uint32(asnNumber),
+			Owner:       asnOwner,
+			CountryCode: asnCountryCode,
+			IPv6Start:   parsedRoutePrefix.FirstUsable().String(),
+			IPv6End:     parsedRoutePrefix.LastUsable().String(),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Int("routes", len(routes)).Msg("Finished processing IPv6 routes")
+	return routes, nil
+}
+
+func lookupASNDetails(details map[int]asnDetails, asnNumber int) (string, string) {
+	if asnDetail, ok := details[asnNumber]; ok {
+		return asnDetail.Owner, asnDetail.CountryCode
+	}
+
+	return "Unknown", "Unknown"
+}
@@ -0,0 +1,264 @@
+package tool
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BGP_RELATION_EXACT         = "exact"
+	BGP_RELATION_LESS_SPECIFIC = "less-specific"
+	BGP_RELATION_MORE_SPECIFIC = "more-specific"
+)
+
+// bgpIndex is an immutable in-memory view of a single BGP route table
+// version. A new index is built and swapped in after each import
+type bgpIndex struct {
+	version int
+	routes  []BGPRoute
+	trie    *prefixtrie.Trie[int]
+	asns    map[uint32][]int
+	// owners is an inverted index of owner name tokens to ASNs, with tokens
+	// holding the sorted keys for prefix matching
+	owners map[string][]uint32
+	tokens []string
+}
+
+// bgpOwnerMatch is an ASN matching an owner search, with a higher score for
+// a closer match
+type bgpOwnerMatch struct {
+	ASN   uint32
+	Score int
+}
+
+func newBGPIndex(version int, routes []BGPRoute) *bgpIndex {
+	index := &bgpIndex{
+		version: version,
+		routes:  routes,
+		trie:    prefixtrie.New[int](),
+		asns:    make(map[uint32][]int),
+		owners:  make(map[string][]uint32),
+	}
+
+	for i, route := range routes {
+		prefix, err := netip.ParsePrefix(route.Route)
+		if err != nil {
+			log.Warn().Err(err).Str("route", route.Route).Msg("Skipping invalid BGP route")
+			continue
+		}
+
+		index.trie.Insert(prefix, i)
+		if _, ok := index.asns[route.ASNNumber]; !ok {
+			for _, token := range searchTokens(route.Owner) {
+				if asns := index.owners[token]; len(asns) == 0 || asns[len(asns)-1] != route.ASNNumber {
+					index.owners[token] = append(asns, route.ASNNumber)
+				}
+			}
+		}
+		index.asns[route.ASNNumber] = append(index.asns[route.ASNNumber], i)
+	}
+
+	for token := range index.owners {
+		index.tokens = append(index.tokens, token)
+	}
+	sort.Strings(index.tokens)
+
+	return index
+}
+
+func (i *bgpIndex) getByASN(asn uint32) []BGPResponseDataItem {
+	var items []BGPResponseDataItem
+	for _, r := range i.asns[asn] {
+		items = append(items, newBGPResponseDataItem(i.routes[r]))
+	}
+
+	return items
+}
+
+// getByIP returns all routes covering the address, least specific first, with
+// the most specific route(s) flagged
+func (i *bgpIndex) getByIP(addr netip.Addr) []BGPResponseDataItem {
+	matches := i.trie.Lookup(addr)
+
+	var items []BGPResponseDataItem
+	for m, match := range matches {
+		for _, r := range match.Values {
+			item := newBGPResponseDataItem(i.routes[r])
+			item.MostSpecific = m == len(matches)-1
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// getByPrefix returns covering (less specific) routes, the exact route and
+// more specific routes for the prefix, in that order
+func (i *bgpIndex) getByPrefix(prefix netip.Prefix, opts bgpQueryOptions) []BGPResponseDataItem {
+	var items []BGPResponseDataItem
+	for _, match := range i.trie.Covering(prefix) {
+		relation := BGP_RELATION_LESS_SPECIFIC
+		if match.Prefix.Bits() == prefix.Bits() {
+			relation = BGP_RELATION_EXACT
+		}
+
+		if (relation == BGP_RELATION_EXACT && !opts.Exact) || (relation == BGP_RELATION_LESS_SPECIFIC && !opts.Less) {
+			continue
+		}
+
+		for _, r := range match.Values {
+			item := newBGPResponseDataItem(i.routes[r])
+			item.Relation = relation
+			items = append(items, item)
+		}
+	}
+
+	if !opts.More {
+		return items
+	}
+
+	for _, match := range i.trie.CoveredBy(prefix) {
+		if match.Prefix.Bits() == prefix.Bits() {
+			continue
+		}
+
+		for _, r := range match.Values {
+			item := newBGPResponseDataItem(i.routes[r])
+			item.Relation = BGP_RELATION_MORE_SPECIFIC
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// getByOwner returns all routes for ASNs matching the owner search
+func (i *bgpIndex) getByOwner(owner string) []BGPResponseDataItem {
+	var items []BGPResponseDataItem
+	for _, match := range i.searchOwners(owner, false) {
+		items = append(items, i.getByASN(match.ASN)...)
+	}
+
+	return items
+}
+
+func (i *bgpIndex) owner(asn uint32) string {
+	if routes := i.asns[asn]; len(routes) > 0 {
+		return i.routes[routes[0]].Owner
+	}
+
+	return ""
+}
+
+// searchOwners returns ASNs whose owner contains every word of the query,
+// allowing words to match by prefix. Whole word matches rank above prefix
+// matches, and an owner equal to the query ranks highest. In exact mode only
+// owners equal to the query are returned. Ties are ranked by route count
+func (i *bgpIndex) searchOwners(query string, exact bool) []bgpOwnerMatch {
+	queryTokens := searchTokens(query)
+	if len(queryTokens) == 0 {
+		return nil
+	}
+
+	var scores map[uint32]int
+	for _, queryToken := range queryTokens {
+		tokenScores := make(map[uint32]int)
+		for t := sort.SearchStrings(i.tokens, queryToken); t < len(i.tokens) && strings.HasPrefix(i.tokens[t], queryToken); t++ {
+			score := 1
+			if i.tokens[t] == queryToken {
+				score = 2
+			}
+
+			for _, asn := range i.owners[i.tokens[t]] {
+				tokenScores[asn] = max(tokenScores[asn], score)
+			}
+		}
+
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+
+		for asn, score := range scores {
+			if tokenScore, ok := tokenScores[asn]; ok {
+				scores[asn] = score + tokenScore
+			} else {
+				delete(scores, asn)
+			}
+		}
+	}
+
+	normalisedQuery := strings.Join(queryTokens, " ")
+
+	var matches []bgpOwnerMatch
+	for asn, score := range scores {
+		equal := strings.Join(searchTokens(i.owner(asn)), " ") == normalisedQuery
+		if exact && !equal {
+			continue
+		}
+		if equal {
+			score += 2 * len(queryTokens)
+		}
+
+		matches = append(matches, bgpOwnerMatch{ASN: asn, Score: score})
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].Score != matches[b].Score {
+			return matches[a].Score > matches[b].Score
+		}
+		if len(i.asns[matches[a].ASN]) != len(i.asns[matches[b].ASN]) {
+			return len(i.asns[matches[a].ASN]) > len(i.asns[matches[b].ASN])
+		}
+		return matches[a].ASN < matches[b].ASN
+	})
+
+	return matches
+}
+
+// getOwnerSummary returns a page of owner search results, with one row per
+// ASN
+func (i *bgpIndex) getOwnerSummary(query string, opts searchOptions) *BGPOwnerResponseData {
+	matches := i.searchOwners(query, opts.Exact)
+
+	response := &BGPOwnerResponseData{
+		SearchPage: newSearchPage(query, opts, len(matches)),
+		Results:    []BGPOwnerResponseDataItem{},
+	}
+
+	start := min(opts.offset(), len(matches))
+	end := min(start+opts.Limit, len(matches))
+	for _, match := range matches[start:end] {
+		item := BGPOwnerResponseDataItem{ASNNumber: match.ASN, Score: match.Score}
+		for _, r := range i.asns[match.ASN] {
+			route := i.routes[r]
+			item.Owner = route.Owner
+			item.CountryCode = route.CountryCode
+			if route.IPVersion == 4 {
+				item.IPv4Prefixes++
+			} else {
+				item.IPv6Prefixes++
+			}
+		}
+		response.Results = append(response.Results, item)
+	}
+
+	return response
+}
+
+func newBGPResponseDataItem(route BGPRoute) BGPResponseDataItem {
+	return BGPResponseDataItem{
+		Route:       route.Route,
+		ASNNumber:   route.ASNNumber,
+		Owner:       route.Owner,
+		CountryCode: route.CountryCode,
+		ASPath:      parseASPath(route.ASPath),
+		Upstreams:   parseASPath(route.Upstreams),
+		PeerCount:   route.PeerCount,
+		Visibility:  route.Visibility,
+	}
+}
@@ -0,0 +1,478 @@
+package tool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-sockaddr"
+	"github.com/rs/zerolog/log"
+)
+
+// MRT record types and subtypes, see RFC 6396
+const (
+	MRT_TYPE_TABLE_DUMP_V2               = 13
+	MRT_SUBTYPE_PEER_INDEX_TABLE         = 1
+	MRT_SUBTYPE_RIB_IPV4_UNICAST         = 2
+	MRT_SUBTYPE_RIB_IPV6_UNICAST         = 4
+	MRT_SUBTYPE_RIB_IPV4_UNICAST_ADDPATH = 8
+	MRT_SUBTYPE_RIB_IPV6_UNICAST_ADDPATH = 10
+	MRT_MAX_RECORD_LENGTH                = 16 * 1024 * 1024
+)
+
+// BGP path attributes and AS_PATH segment types, see RFC 4271
+const (
+	BGP_ATTR_FLAG_EXTENDED_LENGTH = 0x10
+	BGP_ATTR_TYPE_AS_PATH         = 2
+	BGP_AS_PATH_SEGMENT_SET       = 1
+	BGP_AS_PATH_SEGMENT_SEQUENCE  = 2
+)
+
+var errMRTTruncated = errors.New("truncated MRT record")
+
+// mrtRIB is a single TABLE_DUMP_V2 RIB record, holding the paths to a prefix
+// as seen by each collector peer
+type mrtRIB struct {
+	Prefix  netip.Prefix
+	Entries []mrtRIBEntry
+}
+
+type mrtRIBEntry struct {
+	Peer   int
+	Path   []uint32
+	Origin uint32
+	// HasOrigin is false where the path ends in an AS_SET with multiple
+	// members, as the origin is ambiguous, or the path is malformed
+	HasOrigin bool
+}
+
+// mrtBuffer reads big-endian fields from an MRT record, flagging reads past
+// the end of the record
+type mrtBuffer struct {
+	data      []byte
+	truncated bool
+}
+
+func (b *mrtBuffer) bytes(n int) []byte {
+	if n > len(b.data) {
+		b.truncated = true
+		b.data = nil
+		return make([]byte, n)
+	}
+
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+func (b *mrtBuffer) uint8() uint8 {
+	return b.bytes(1)[0]
+}
+
+func (b *mrtBuffer) uint16() uint16 {
+	return binary.BigEndian.Uint16(b.bytes(2))
+}
+
+func (b *mrtBuffer) uint32() uint32 {
+	return binary.BigEndian.Uint32(b.bytes(4))
+}
+
+// parseMRT calls fn for each IPv4 and IPv6 unicast RIB record in a
+// TABLE_DUMP_V2 file. Other record types are skipped
+func parseMRT(r io.Reader, fn func(rib *mrtRIB)) error {
+	reader := bufio.NewReaderSize(r, 1024*1024)
+	header := make([]byte, 12)
+
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		recordType := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+		if length > MRT_MAX_RECORD_LENGTH {
+			return fmt.Errorf("invalid MRT record length %d", length)
+		}
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		if err != nil {
+			return errMRTTruncated
+		}
+
+		if recordType != MRT_TYPE_TABLE_DUMP_V2 {
+			continue
+		}
+
+		var rib *mrtRIB
+		switch subtype {
+		case MRT_SUBTYPE_RIB_IPV4_UNICAST:
+			rib, err = parseMRTRIB(body, 32, false)
+		case MRT_SUBTYPE_RIB_IPV6_UNICAST:
+			rib, err = parseMRTRIB(body, 128, false)
+		case MRT_SUBTYPE_RIB_IPV4_UNICAST_ADDPATH:
+			rib, err = parseMRTRIB(body, 32, true)
+		case MRT_SUBTYPE_RIB_IPV6_UNICAST_ADDPATH:
+			rib, err = parseMRTRIB(body, 128, true)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if rib != nil {
+			fn(rib)
+		}
+	}
+}
+
+func parseMRTRIB(data []byte, bits int, addPath bool) (*mrtRIB, error) {
+	buffer := &mrtBuffer{data: data}
+
+	buffer.uint32() // Sequence number
+	length := int(buffer.uint8())
+	if length > bits {
+		return nil, fmt.Errorf("invalid MRT prefix length %d", length)
+	}
+
+	addr := make([]byte, bits/8)
+	copy(addr, buffer.bytes((length+7)/8))
+
+	ip, _ := netip.AddrFromSlice(addr)
+	rib := &mrtRIB{Prefix: netip.PrefixFrom(ip, length).Masked()}
+
+	count := int(buffer.uint16())
+	for i := 0; i < count && !buffer.truncated; i++ {
+		entry := mrtRIBEntry{Peer: int(buffer.uint16())}
+		buffer.uint32() // Originated time
+		if addPath {
+			buffer.uint32() // Path identifier
+		}
+
+		attributes := buffer.bytes(int(buffer.uint16()))
+		if buffer.truncated {
+			break
+		}
+
+		err := parseMRTAttributes(attributes, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		rib.Entries = append(rib.Entries, entry)
+	}
+
+	if buffer.truncated {
+		return nil, errMRTTruncated
+	}
+
+	return rib, nil
+}
+
+func parseMRTAttributes(data []byte, entry *mrtRIBEntry) error {
+	buffer := &mrtBuffer{data: data}
+
+	for len(buffer.data) > 0 {
+		flags := buffer.uint8()
+		attributeType := buffer.uint8()
+
+		length := int(buffer.uint8())
+		if flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+			length = length<<8 | int(buffer.uint8())
+		}
+
+		value := buffer.bytes(length)
+		if buffer.truncated {
+			return errMRTTruncated
+		}
+
+		if attributeType == BGP_ATTR_TYPE_AS_PATH {
+			err := parseMRTASPath(value, entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseMRTASPath parses an AS_PATH attribute, which TABLE_DUMP_V2 always
+// encodes with 4 byte ASNs. Paths containing AS0 are malformed (RFC 7607), so
+// have no origin
+func parseMRTASPath(data []byte, entry *mrtRIBEntry) error {
+	buffer := &mrtBuffer{data: data}
+
+	entry.Path = nil
+	entry.HasOrigin = false
+	malformed := false
+	for len(buffer.data) > 0 {
+		segmentType := buffer.uint8()
+		count := int(buffer.uint8())
+
+		var asns []uint32
+		for i := 0; i < count; i++ {
+			asn := buffer.uint32()
+			malformed = malformed || asn == 0
+			asns = append(asns, asn)
+		}
+		if buffer.truncated {
+			return errMRTTruncated
+		}
+
+		switch segmentType {
+		case BGP_AS_PATH_SEGMENT_SEQUENCE:
+			entry.Path = append(entry.Path, asns...)
+			entry.HasOrigin = len(asns) > 0
+			if entry.HasOrigin {
+				entry.Origin = asns[len(asns)-1]
+			}
+		case BGP_AS_PATH_SEGMENT_SET:
+			entry.HasOrigin = len(asns) == 1
+			if entry.HasOrigin {
+				entry.Path = append(entry.Path, asns[0])
+				entry.Origin = asns[0]
+			}
+		}
+	}
+
+	if malformed {
+		entry.HasOrigin = false
+	}
+
+	return nil
+}
+
+// mrtUpstream returns the first ASN preceding the origin in the path,
+// ignoring prepending
+func mrtUpstream(path []uint32, origin uint32) (uint32, bool) {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] != origin {
+			return path[i], true
+		}
+	}
+
+	return 0, false
+}
+
+type mrtRouteKey struct {
+	prefix netip.Prefix
+	origin uint32
+}
+
+type mrtRouteStats struct {
+	peers     int
+	path      []uint32
+	pathPeers int
+	upstreams map[uint32]bool
+}
+
+// mrtAggregator combines RIB records from one or more MRT files into a route
+// per prefix and origin ASN
+type mrtAggregator struct {
+	routes map[mrtRouteKey]*mrtRouteStats
+	// peers holds the number of collector peers contributing IPv4 and IPv6
+	// routes, which visibility is measured against
+	peers map[int]int
+}
+
+func newMRTAggregator() *mrtAggregator {
+	return &mrtAggregator{
+		routes: make(map[mrtRouteKey]*mrtRouteStats),
+		peers:  make(map[int]int),
+	}
+}
+
+func (a *mrtAggregator) addFile(r io.Reader) error {
+	filePeers := map[int]map[int]bool{4: {}, 6: {}}
+
+	err := parseMRT(r, func(rib *mrtRIB) {
+		ipVersion := 6
+		if rib.Prefix.Addr().Is4() {
+			ipVersion = 4
+		}
+
+		type originStats struct {
+			peers map[int]bool
+			paths map[string]int
+			best  []uint32
+			count int
+		}
+		origins := make(map[uint32]*originStats)
+
+		for _, entry := range rib.Entries {
+			filePeers[ipVersion][entry.Peer] = true
+			if !entry.HasOrigin {
+				continue
+			}
+
+			stats, ok := origins[entry.Origin]
+			if !ok {
+				stats = &originStats{peers: make(map[int]bool), paths: make(map[string]int)}
+				origins[entry.Origin] = stats
+			}
+			stats.peers[entry.Peer] = true
+
+			key := formatASPath(entry.Path)
+			stats.paths[key]++
+			if stats.paths[key] > stats.count {
+				stats.best = entry.Path
+				stats.count = stats.paths[key]
+			}
+
+			if upstream, ok := mrtUpstream(entry.Path, entry.Origin); ok {
+				route := a.route(mrtRouteKey{prefix: rib.Prefix, origin: entry.Origin})
+				route.upstreams[upstream] = true
+			}
+		}
+
+		for origin, stats := range origins {
+			route := a.route(mrtRouteKey{prefix: rib.Prefix, origin: origin})
+			route.peers += len(stats.peers)
+			if stats.count > route.pathPeers {
+				route.path = stats.best
+				route.pathPeers = stats.count
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	a.peers[4] += len(filePeers[4])
+	a.peers[6] += len(filePeers[6])
+
+	return nil
+}
+
+func (a *mrtAggregator) route(key mrtRouteKey) *mrtRouteStats {
+	route, ok := a.routes[key]
+	if !ok {
+		route = &mrtRouteStats{upstreams: make(map[uint32]bool)}
+		a.routes[key] = route
+	}
+
+	return route
+}
+
+func (a *mrtAggregator) bgpRoutes(asnDetails map[int]asnDetails) ([]BGPRoute, error) {
+	var routes []BGPRoute
+	for key, stats := range a.routes {
+		asnOwner, asnCountryCode := lookupASNDetails(asnDetails, int(key.origin))
+
+		route := BGPRoute{
+			Route:       key.prefix.String(),
+			ASNNumber:   key.origin,
+			Owner:       asnOwner,
+			CountryCode: asnCountryCode,
+			ASPath:      formatASPath(stats.path),
+			Upstreams:   formatASPath(sortedASNs(stats.upstreams)),
+			PeerCount:   stats.peers,
+		}
+
+		if key.prefix.Addr().Is4() {
+			parsedRoutePrefix, err := sockaddr.NewIPv4Addr(route.Route)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse route: %s", err.Error())
+			}
+
+			route.IPVersion = 4
+			route.IPv4Start = uint32(parsedRoutePrefix.NetworkAddress())
+			route.IPv4End = uint32(parsedRoutePrefix.BroadcastAddress())
+		} else {
+			parsedRoutePrefix, err := sockaddr.NewIPv6Addr(route.Route)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse route: %s", err.Error())
+			}
+
+			route.IPVersion = 6
+			route.IPv6Start = parsedRoutePrefix.FirstUsable().String()
+			route.IPv6End = parsedRoutePrefix.LastUsable().String()
+		}
+
+		if peers := a.peers[route.IPVersion]; peers > 0 {
+			route.Visibility = min(100, float64(stats.peers)/float64(peers)*100)
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// fetchMRTRoutes builds the route table from the configured MRT RIB dumps in
+// place of the APNIC tables
+func (b *BGP) fetchMRTRoutes(asnDetails map[int]asnDetails) ([]BGPRoute, error) {
+	aggregator := newMRTAggregator()
+	for _, source := range b.mrtSources {
+		log.Debug().Str("source", source).Msg("Processing MRT RIB dump")
+
+		err := b.loadMRTSource(aggregator, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MRT RIB dump %s: %w", source, err)
+		}
+	}
+
+	routes, err := aggregator.bgpRoutes(asnDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().Int("routes", len(routes)).Int("ipv4_peers", aggregator.peers[4]).Int("ipv6_peers", aggregator.peers[6]).Msg("Finished processing MRT RIB dumps")
+	return routes, nil
+}
+
+func (b *BGP) loadMRTSource(aggregator *mrtAggregator, source string) error {
+	body, err := openSource(source)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	return aggregator.addFile(body)
+}
+
+func formatASPath(path []uint32) string {
+	asns := make([]string, len(path))
+	for i, asn := range path {
+		asns[i] = strconv.FormatUint(uint64(asn), 10)
+	}
+
+	return strings.Join(asns, " ")
+}
+
+func parseASPath(path string) []uint32 {
+	var asns []uint32
+	for _, field := range strings.Fields(path) {
+		asn, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			continue
+		}
+		asns = append(asns, uint32(asn))
+	}
+
+	return asns
+}
+
+func sortedASNs(set map[uint32]bool) []uint32 {
+	asns := make([]uint32, 0, len(set))
+	for asn := range set {
+		asns = append(asns, asn)
+	}
+	sort.Slice(asns, func(i, j int) bool { return asns[i] < asns[j] })
+
+	return asns
+}
@@ -0,0 +1,485 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+type mrtTestSegment struct {
+	segmentType uint8
+	asns        []uint32
+}
+
+type mrtTestEntry struct {
+	peer   uint16
+	asPath []mrtTestSegment
+}
+
+func mrtTestSequence(asns ...uint32) mrtTestSegment {
+	return mrtTestSegment{segmentType: BGP_AS_PATH_SEGMENT_SEQUENCE, asns: asns}
+}
+
+func mrtTestSet(asns ...uint32) mrtTestSegment {
+	return mrtTestSegment{segmentType: BGP_AS_PATH_SEGMENT_SET, asns: asns}
+}
+
+func mrtTestASPath(segments ...mrtTestSegment) []byte {
+	var b []byte
+	for _, segment := range segments {
+		b = append(b, segment.segmentType, uint8(len(segment.asns)))
+		for _, asn := range segment.asns {
+			b = binary.BigEndian.AppendUint32(b, asn)
+		}
+	}
+
+	return b
+}
+
+func mrtTestAttribute(attributeType uint8, value []byte) []byte {
+	if len(value) > 255 {
+		b := []byte{0x40 | BGP_ATTR_FLAG_EXTENDED_LENGTH, attributeType}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+		return append(b, value...)
+	}
+
+	return append([]byte{0x40, attributeType, uint8(len(value))}, value...)
+}
+
+func mrtTestRIB(prefix netip.Prefix, addPath bool, entries ...mrtTestEntry) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 1)
+	b = append(b, uint8(prefix.Bits()))
+	b = append(b, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(entries)))
+
+	for _, entry := range entries {
+		b = binary.BigEndian.AppendUint16(b, entry.peer)
+		b = binary.BigEndian.AppendUint32(b, 0)
+		if addPath {
+			b = binary.BigEndian.AppendUint32(b, 1)
+		}
+
+		// An ORIGIN attribute ahead of the AS_PATH, which should be skipped
+		attributes := mrtTestAttribute(1, []byte{0})
+		attributes = append(attributes, mrtTestAttribute(BGP_ATTR_TYPE_AS_PATH, mrtTestASPath(entry.asPath...))...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(attributes)))
+		b = append(b, attributes...)
+	}
+
+	return b
+}
+
+func mrtTestRecord(recordType uint16, subtype uint16, body []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 0)
+	b = binary.BigEndian.AppendUint16(b, recordType)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+
+	return append(b, body...)
+}
+
+func TestParseMRTASPath(t *testing.T) {
+	longPath := make([]uint32, 70)
+	for i := range longPath {
+		longPath[i] = uint32(64500 + i)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		path      []uint32
+		origin    uint32
+		hasOrigin bool
+		err       error
+	}{
+		{
+			name:      "sequence",
+			data:      mrtTestASPath(mrtTestSequence(3356, 13335)),
+			path:      []uint32{3356, 13335},
+			origin:    13335,
+			hasOrigin: true,
+		},
+		{
+			name:      "prepended origin",
+			data:      mrtTestASPath(mrtTestSequence(3356, 13335, 13335, 13335)),
+			path:      []uint32{3356, 13335, 13335, 13335},
+			origin:    13335,
+			hasOrigin: true,
+		},
+		{
+			name:      "32-bit ASNs",
+			data:      mrtTestASPath(mrtTestSequence(4200000000, 4294967294)),
+			path:      []uint32{4200000000, 4294967294},
+			origin:    4294967294,
+			hasOrigin: true,
+		},
+		{
+			name:      "single member AS_SET origin",
+			data:      mrtTestASPath(mrtTestSequence(3356), mrtTestSet(64500)),
+			path:      []uint32{3356, 64500},
+			origin:    64500,
+			hasOrigin: true,
+		},
+		{
+			name: "multiple member AS_SET origin",
+			data: mrtTestASPath(mrtTestSequence(3356), mrtTestSet(64500, 64501)),
+			path: []uint32{3356},
+		},
+		{
+			name:      "AS_SET followed by sequence",
+			data:      mrtTestASPath(mrtTestSet(64500, 64501), mrtTestSequence(64502)),
+			path:      []uint32{64502},
+			origin:    64502,
+			hasOrigin: true,
+		},
+		{
+			name: "AS0 origin",
+			data: mrtTestASPath(mrtTestSequence(3356, 0)),
+			path: []uint32{3356, 0},
+		},
+		{
+			name: "AS0 transit",
+			data: mrtTestASPath(mrtTestSequence(0, 13335)),
+			path: []uint32{0, 13335},
+		},
+		{
+			name: "AS0 in AS_SET",
+			data: mrtTestASPath(mrtTestSequence(3356), mrtTestSet(0)),
+			path: []uint32{3356, 0},
+		},
+		{
+			name: "empty",
+			data: nil,
+		},
+		{
+			name:      "long path",
+			data:      mrtTestASPath(mrtTestSequence(longPath...)),
+			path:      longPath,
+			origin:    longPath[len(longPath)-1],
+			hasOrigin: true,
+		},
+		{
+			name: "truncated ASN",
+			data: mrtTestASPath(mrtTestSequence(3356, 13335))[:8],
+			err:  errMRTTruncated,
+		},
+		{
+			name: "truncated segment header",
+			data: []byte{BGP_AS_PATH_SEGMENT_SEQUENCE},
+			err:  errMRTTruncated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var entry mrtRIBEntry
+			err := parseMRTASPath(test.data, &entry)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if test.err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(entry.Path, test.path) {
+				t.Errorf("expected path %v, got %v", test.path, entry.Path)
+			}
+			if entry.HasOrigin != test.hasOrigin {
+				t.Errorf("expected has origin %t, got %t", test.hasOrigin, entry.HasOrigin)
+			}
+			if test.hasOrigin && entry.Origin != test.origin {
+				t.Errorf("expected origin %d, got %d", test.origin, entry.Origin)
+			}
+		})
+	}
+}
+
+func TestParseMRTRIB(t *testing.T) {
+	ipv4 := netip.MustParsePrefix("192.0.2.0/24")
+	ipv6 := netip.MustParsePrefix("2001:db8::/32")
+	entry := mrtTestEntry{peer: 3, asPath: []mrtTestSegment{mrtTestSequence(3356, 13335)}}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		bits    int
+		addPath bool
+		prefix  netip.Prefix
+		entries []mrtRIBEntry
+		err     bool
+	}{
+		{
+			name:    "IPv4",
+			data:    mrtTestRIB(ipv4, false, entry),
+			bits:    32,
+			prefix:  ipv4,
+			entries: []mrtRIBEntry{{Peer: 3, Path: []uint32{3356, 13335}, Origin: 13335, HasOrigin: true}},
+		},
+		{
+			name:    "IPv6",
+			data:    mrtTestRIB(ipv6, false, entry),
+			bits:    128,
+			prefix:  ipv6,
+			entries: []mrtRIBEntry{{Peer: 3, Path: []uint32{3356, 13335}, Origin: 13335, HasOrigin: true}},
+		},
+		{
+			name:    "IPv4 with path identifiers",
+			data:    mrtTestRIB(ipv4, true, entry),
+			bits:    32,
+			addPath: true,
+			prefix:  ipv4,
+			entries: []mrtRIBEntry{{Peer: 3, Path: []uint32{3356, 13335}, Origin: 13335, HasOrigin: true}},
+		},
+		{
+			name:   "default route",
+			data:   mrtTestRIB(netip.MustParsePrefix("0.0.0.0/0"), false),
+			bits:   32,
+			prefix: netip.MustParsePrefix("0.0.0.0/0"),
+		},
+		{
+			name:   "unaligned prefix length",
+			data:   mrtTestRIB(netip.MustParsePrefix("198.51.100.0/22"), false),
+			bits:   32,
+			prefix: netip.MustParsePrefix("198.51.100.0/22"),
+		},
+		{
+			name: "prefix length exceeding address family",
+			data: append([]byte{0, 0, 0, 1, 33}, make([]byte, 7)...),
+			bits: 32,
+			err:  true,
+		},
+		{
+			name: "truncated prefix",
+			data: []byte{0, 0, 0, 1, 24, 192},
+			bits: 32,
+			err:  true,
+		},
+		{
+			name: "truncated entry",
+			data: func() []byte {
+				data := mrtTestRIB(ipv4, false, entry)
+				return data[:len(data)-3]
+			}(),
+			bits: 32,
+			err:  true,
+		},
+		{
+			name: "entry count exceeding entries",
+			data: func() []byte {
+				data := mrtTestRIB(ipv4, false, entry)
+				binary.BigEndian.PutUint16(data[8:10], 2)
+				return data
+			}(),
+			bits: 32,
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rib, err := parseMRTRIB(test.data, test.bits, test.addPath)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if rib.Prefix != test.prefix {
+				t.Errorf("expected prefix %s, got %s", test.prefix, rib.Prefix)
+			}
+			if !reflect.DeepEqual(rib.Entries, test.entries) {
+				t.Errorf("expected entries %+v, got %+v", test.entries, rib.Entries)
+			}
+		})
+	}
+}
+
+func TestParseMRTAttributes(t *testing.T) {
+	path := make([]uint32, 70)
+	for i := range path {
+		path[i] = uint32(64500 + i)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		path []uint32
+		err  error
+	}{
+		{
+			name: "extended length AS_PATH",
+			data: mrtTestAttribute(BGP_ATTR_TYPE_AS_PATH, mrtTestASPath(mrtTestSequence(path...))),
+			path: path,
+		},
+		{
+			name: "no AS_PATH",
+			data: mrtTestAttribute(1, []byte{0}),
+		},
+		{
+			name: "truncated attribute",
+			data: mrtTestAttribute(BGP_ATTR_TYPE_AS_PATH, mrtTestASPath(mrtTestSequence(13335)))[:5],
+			err:  errMRTTruncated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var entry mrtRIBEntry
+			err := parseMRTAttributes(test.data, &entry)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if test.err == nil && !reflect.DeepEqual(entry.Path, test.path) {
+				t.Errorf("expected path %v, got %v", test.path, entry.Path)
+			}
+		})
+	}
+}
+
+func TestParseMRT(t *testing.T) {
+	ipv4 := netip.MustParsePrefix("192.0.2.0/24")
+	ipv6 := netip.MustParsePrefix("2001:db8::/32")
+	entry := mrtTestEntry{peer: 0, asPath: []mrtTestSegment{mrtTestSequence(3356, 13335)}}
+
+	var file []byte
+	file = append(file, mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_PEER_INDEX_TABLE, []byte{1, 2, 3, 4})...)
+	file = append(file, mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV4_UNICAST, mrtTestRIB(ipv4, false, entry))...)
+	file = append(file, mrtTestRecord(16, 4, []byte{1, 2, 3})...)
+	file = append(file, mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV6_UNICAST_ADDPATH, mrtTestRIB(ipv6, true, entry))...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		prefixes []netip.Prefix
+		err      bool
+	}{
+		{
+			name:     "RIB records",
+			data:     file,
+			prefixes: []netip.Prefix{ipv4, ipv6},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name: "truncated header",
+			data: file[:6],
+			err:  true,
+		},
+		{
+			name:     "truncated body",
+			data:     file[:len(file)-1],
+			prefixes: []netip.Prefix{ipv4},
+			err:      true,
+		},
+		{
+			name: "excessive record length",
+			data: func() []byte {
+				data := mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV4_UNICAST, nil)
+				binary.BigEndian.PutUint32(data[8:12], MRT_MAX_RECORD_LENGTH+1)
+				return data
+			}(),
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var prefixes []netip.Prefix
+			err := parseMRT(bytes.NewReader(test.data), func(rib *mrtRIB) {
+				prefixes = append(prefixes, rib.Prefix)
+			})
+			if test.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+
+			if !reflect.DeepEqual(prefixes, test.prefixes) {
+				t.Errorf("expected prefixes %v, got %v", test.prefixes, prefixes)
+			}
+		})
+	}
+}
+
+func TestMRTAggregator(t *testing.T) {
+	ipv4 := netip.MustParsePrefix("192.0.2.0/24")
+	moas := netip.MustParsePrefix("198.51.100.0/24")
+	ipv6 := netip.MustParsePrefix("2001:db8::/32")
+
+	collector1 := mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV4_UNICAST, mrtTestRIB(ipv4, false,
+		mrtTestEntry{peer: 0, asPath: []mrtTestSegment{mrtTestSequence(174, 3356, 13335)}},
+		mrtTestEntry{peer: 1, asPath: []mrtTestSegment{mrtTestSequence(174, 3356, 13335)}},
+		mrtTestEntry{peer: 2, asPath: []mrtTestSegment{mrtTestSequence(6939, 13335, 13335)}},
+		// Ambiguous and malformed paths count towards peers, but not routes
+		mrtTestEntry{peer: 3, asPath: []mrtTestSegment{mrtTestSequence(6939), mrtTestSet(64500, 64501)}},
+		mrtTestEntry{peer: 4, asPath: []mrtTestSegment{mrtTestSequence(6939, 0)}},
+	))
+	collector1 = append(collector1, mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV4_UNICAST, mrtTestRIB(moas, false,
+		mrtTestEntry{peer: 0, asPath: []mrtTestSegment{mrtTestSequence(174, 64500)}},
+		mrtTestEntry{peer: 1, asPath: []mrtTestSegment{mrtTestSequence(3356, 64501)}},
+	))...)
+	collector1 = append(collector1, mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV6_UNICAST, mrtTestRIB(ipv6, false,
+		mrtTestEntry{peer: 0, asPath: []mrtTestSegment{mrtTestSequence(6939, 64502)}},
+	))...)
+
+	// Peer indexes are local to each file, so peers of a second collector
+	// are counted separately
+	collector2 := mrtTestRecord(MRT_TYPE_TABLE_DUMP_V2, MRT_SUBTYPE_RIB_IPV4_UNICAST, mrtTestRIB(ipv4, false,
+		mrtTestEntry{peer: 0, asPath: []mrtTestSegment{mrtTestSequence(2914, 13335)}},
+	))
+
+	aggregator := newMRTAggregator()
+	for _, file := range [][]byte{collector1, collector2} {
+		if err := aggregator.addFile(bytes.NewReader(file)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if aggregator.peers[4] != 6 || aggregator.peers[6] != 1 {
+		t.Fatalf("expected 6 IPv4 and 1 IPv6 peers, got %d and %d", aggregator.peers[4], aggregator.peers[6])
+	}
+
+	routes, err := aggregator.bgpRoutes(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type route struct {
+		asPath     string
+		upstreams  string
+		peerCount  int
+		visibility float64
+	}
+	expected := map[mrtRouteKey]route{
+		{prefix: ipv4, origin: 13335}: {asPath: "174 3356 13335", upstreams: "2914 3356 6939", peerCount: 4, visibility: 4.0 / 6 * 100},
+		{prefix: moas, origin: 64500}: {asPath: "174 64500", upstreams: "174", peerCount: 1, visibility: 1.0 / 6 * 100},
+		{prefix: moas, origin: 64501}: {asPath: "3356 64501", upstreams: "3356", peerCount: 1, visibility: 1.0 / 6 * 100},
+		{prefix: ipv6, origin: 64502}: {asPath: "6939 64502", upstreams: "6939", peerCount: 1, visibility: 100},
+	}
+
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %d routes, got %d: %+v", len(expected), len(routes), routes)
+	}
+
+	for _, r := range routes {
+		key := mrtRouteKey{prefix: netip.MustParsePrefix(r.Route), origin: r.ASNNumber}
+		want, ok := expected[key]
+		if !ok {
+			t.Errorf("unexpected route %s from AS%d", r.Route, r.ASNNumber)
+			continue
+		}
+
+		got := route{asPath: r.ASPath, upstreams: r.Upstreams, peerCount: r.PeerCount, visibility: r.Visibility}
+		if got.asPath != want.asPath || got.upstreams != want.upstreams || got.peerCount != want.peerCount || math.Abs(got.visibility-want.visibility) > 1e-9 {
+			t.Errorf("route %s from AS%d: expected %+v, got %+v", r.Route, r.ASNNumber, want, got)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-sockaddr"
+)
+
+const BGP_PREFIX_LIST_DEFAULT_FORMAT = "cisco"
+
+var bgpPrefixListNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// prefixListFormat renders prefix lists for a router platform. Platforms
+// which can't mix address families in a single list always have IPv4 and
+// IPv6 routes split into separate lists
+type prefixListFormat struct {
+	mixed  bool
+	render func(lists []prefixList) string
+}
+
+var bgpPrefixListFormats = map[string]prefixListFormat{
+	"cisco":   {mixed: false, render: renderPrefixListCisco},
+	"iosxr":   {mixed: true, render: renderPrefixListIOSXR},
+	"juniper": {mixed: true, render: renderPrefixListJuniper},
+	"bird":    {mixed: false, render: renderPrefixListBIRD},
+	"frr":     {mixed: false, render: renderPrefixListFRR},
+	"arista":  {mixed: false, render: renderPrefixListArista},
+}
+
+// prefixListEntry matches routes within Prefix with a prefix length between
+// Min and Max inclusive
+type prefixListEntry struct {
+	Prefix sockaddr.IPAddr
+	Min    int
+	Max    int
+}
+
+func (e prefixListEntry) exact() bool {
+	return e.Min == e.Prefix.Maskbits() && e.Max == e.Prefix.Maskbits()
+}
+
+// covers returns whether every route matched by o is also matched by e
+func (e prefixListEntry) covers(o prefixListEntry) bool {
+	return e.Prefix.Contains(o.Prefix) && e.Min <= o.Min && o.Max <= e.Max
+}
+
+type prefixList struct {
+	Name    string
+	Family  int
+	Entries []prefixListEntry
+}
+
+type BGPPrefixList struct {
+	bgp *BGP
+}
+
+func NewBGPPrefixList(bgp *BGP) *BGPPrefixList {
+	return &BGPPrefixList{bgp: bgp}
+}
+
+func (p *BGPPrefixList) Paths() []string {
+	return []string{"/bgp/prefixlist/{query}"}
+}
+
+func (p *BGPPrefixList) Method() string {
+	return "GET"
+}
+
+func (p *BGPPrefixList) Handle(r *http.Request) (*ToolResponse, error) {
+	query := mux.Vars(r)["query"]
+	params := r.URL.Query()
+
+	formatName := strings.ToLower(params.Get("format"))
+	if formatName == "" {
+		formatName = BGP_PREFIX_LIST_DEFAULT_FORMAT
+	}
+	format, ok := bgpPrefixListFormats[formatName]
+	if !ok {
+		return nil, errors.New("unsupported prefix list format")
+	}
+
+	le4, err := parsePrefixListLength(params.Get("le4"), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	le6, err := parsePrefixListLength(params.Get("le6"), 128)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := p.bgp.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var routes BGPResponseData
+	name := params.Get("name")
+	if asn, err := parseASN(query); err == nil {
+		routes = index.getByASN(asn)
+		if name == "" {
+			name = fmt.Sprintf("AS%d", asn)
+		}
+	} else {
+		routes = index.getByOwner(query)
+		if name == "" {
+			name = strings.ToUpper(query)
+		}
+	}
+	name = strings.Trim(bgpPrefixListNameRegexp.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		return nil, errors.New("invalid prefix list name")
+	}
+
+	var ipv4Entries, ipv6Entries []prefixListEntry
+	for _, route := range routes {
+		prefix, err := sockaddr.NewIPAddr(route.Route)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Network()
+
+		entry := prefixListEntry{Prefix: prefix, Min: prefix.Maskbits(), Max: prefix.Maskbits()}
+		if prefix.Type() == sockaddr.TypeIPv4 {
+			entry.Max = max(entry.Max, le4)
+			ipv4Entries = append(ipv4Entries, entry)
+		} else {
+			entry.Max = max(entry.Max, le6)
+			ipv6Entries = append(ipv6Entries, entry)
+		}
+	}
+
+	if len(ipv4Entries) == 0 && len(ipv6Entries) == 0 {
+		return nil, NewBGPNotFoundError("no routes found for query")
+	}
+
+	ipv4Entries = aggregatePrefixListEntries(ipv4Entries)
+	ipv6Entries = aggregatePrefixListEntries(ipv6Entries)
+
+	var lists []prefixList
+	if format.mixed && !params.Has("split") {
+		lists = append(lists, prefixList{Name: name, Entries: append(ipv4Entries, ipv6Entries...)})
+	} else {
+		ipv4Name, ipv6Name := name, name
+		if len(ipv4Entries) > 0 && len(ipv6Entries) > 0 {
+			ipv4Name, ipv6Name = name+"-v4", name+"-v6"
+		}
+		if len(ipv4Entries) > 0 {
+			lists = append(lists, prefixList{Name: ipv4Name, Family: 4, Entries: ipv4Entries})
+		}
+		if len(ipv6Entries) > 0 {
+			lists = append(lists, prefixList{Name: ipv6Name, Family: 6, Entries: ipv6Entries})
+		}
+	}
+
+	response := &BGPPrefixListResponseData{
+		Format: formatName,
+		Config: format.render(lists),
+	}
+	for _, list := range lists {
+		responseList := BGPPrefixListResponseDataList{Name: list.Name, Family: list.Family}
+		for _, entry := range list.Entries {
+			responseList.Entries = append(responseList.Entries, BGPPrefixListResponseDataEntry{
+				Prefix: entry.Prefix.String(),
+				Min:    entry.Min,
+				Max:    entry.Max,
+			})
+		}
+		response.Lists = append(response.Lists, responseList)
+	}
+
+	return NewToolResponse(response), nil
+}
+
+func parsePrefixListLength(v string, maxLength int) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	length, err := strconv.Atoi(v)
+	if err != nil || length < 0 || length > maxLength {
+		return 0, errors.New("invalid max prefix length")
+	}
+
+	return length, nil
+}
+
+// aggregatePrefixListEntries removes entries covered by other entries and
+// merges sibling entries with matching length ranges into their supernet,
+// repeating until no further aggregation is possible. The set of routes
+// matched by the entries is unchanged
+func aggregatePrefixListEntries(entries []prefixListEntry) []prefixListEntry {
+	for {
+		entries = removeCoveredPrefixListEntries(entries)
+
+		merged, ok := mergeSiblingPrefixListEntries(entries)
+		if !ok {
+			return entries
+		}
+		entries = merged
+	}
+}
+
+func sortPrefixListEntries(entries []prefixListEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c := entries[i].Prefix.CmpAddress(entries[j].Prefix); c != 0 {
+			return c < 0
+		}
+		if entries[i].Prefix.Maskbits() != entries[j].Prefix.Maskbits() {
+			return entries[i].Prefix.Maskbits() < entries[j].Prefix.Maskbits()
+		}
+		return entries[i].Max > entries[j].Max
+	})
+}
+
+func removeCoveredPrefixListEntries(entries []prefixListEntry) []prefixListEntry {
+	sortPrefixListEntries(entries)
+
+	var result []prefixListEntry
+	// Entries whose prefix contains the current entry, least specific first
+	var parents []prefixListEntry
+	for _, entry := range entries {
+		for len(parents) > 0 && !parents[len(parents)-1].Prefix.Contains(entry.Prefix) {
+			parents = parents[:len(parents)-1]
+		}
+
+		covered := false
+		for _, parent := range parents {
+			if parent.covers(entry) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+
+		result = append(result, entry)
+		parents = append(parents, entry)
+	}
+
+	return result
+}
+
+func mergeSiblingPrefixListEntries(entries []prefixListEntry) ([]prefixListEntry, bool) {
+	type siblingKey struct {
+		supernet string
+		min      int
+		max      int
+	}
+
+	supernets := make(map[siblingKey]sockaddr.IPAddr)
+	siblings := make(map[siblingKey][]int)
+	for i, entry := range entries {
+		bits := entry.Prefix.Maskbits()
+		if bits == 0 {
+			continue
+		}
+
+		supernet, err := sockaddr.NewIPAddr(fmt.Sprintf("%s/%d", entry.Prefix.NetIP(), bits-1))
+		if err != nil {
+			continue
+		}
+		supernet = supernet.Network()
+
+		key := siblingKey{supernet: supernet.String(), min: entry.Min, max: entry.Max}
+		supernets[key] = supernet
+		siblings[key] = append(siblings[key], i)
+	}
+
+	merged := make(map[int]bool)
+	var result []prefixListEntry
+	for key, indexes := range siblings {
+		if len(indexes) != 2 {
+			continue
+		}
+
+		merged[indexes[0]] = true
+		merged[indexes[1]] = true
+		result = append(result, prefixListEntry{Prefix: supernets[key], Min: key.min, Max: key.max})
+	}
+
+	if len(result) == 0 {
+		return entries, false
+	}
+
+	for i, entry := range entries {
+		if !merged[i] {
+			result = append(result, entry)
+		}
+	}
+
+	return result, true
+}
+
+// ciscoPrefixRange renders the ge/le suffix used by Cisco style platforms
+func ciscoPrefixRange(entry prefixListEntry) string {
+	bits := entry.Prefix.Maskbits()
+	switch {
+	case entry.Min > bits:
+		return fmt.Sprintf(" ge %d le %d", entry.Min, entry.Max)
+	case entry.Max > bits:
+		return fmt.Sprintf(" le %d", entry.Max)
+	}
+
+	return ""
+}
+
+func ciscoPrefixListCommand(family int) string {
+	if family == 6 {
+		return "ipv6 prefix-list"
+	}
+
+	return "ip prefix-list"
+}
+
+func renderPrefixListCisco(lists []prefixList) string {
+	var lines []string
+	for _, list := range lists {
+		command := ciscoPrefixListCommand(list.Family)
+		lines = append(lines, fmt.Sprintf("no %s %s", command, list.Name))
+		for i, entry := range list.Entries {
+			lines = append(lines, fmt.Sprintf("%s %s seq %d permit %s%s", command, list.Name, (i+1)*5, entry.Prefix, ciscoPrefixRange(entry)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderPrefixListFRR(lists []prefixList) string {
+	// FRR shares Cisco IOS syntax
+	return renderPrefixListCisco(lists)
+}
+
+func renderPrefixListArista(lists []prefixList) string {
+	var lines []string
+	for _, list := range lists {
+		command := ciscoPrefixListCommand(list.Family)
+		lines = append(lines, fmt.Sprintf("no %s %s", command, list.Name), fmt.Sprintf("%s %s", command, list.Name))
+		for i, entry := range list.Entries {
+			lines = append(lines, fmt.Sprintf("   seq %d permit %s%s", (i+1)*10, entry.Prefix, ciscoPrefixRange(entry)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderPrefixListIOSXR(lists []prefixList) string {
+	var lines []string
+	for _, list := range lists {
+		lines = append(lines, fmt.Sprintf("prefix-set %s", list.Name))
+		for i, entry := range list.Entries {
+			separator := ","
+			if i == len(list.Entries)-1 {
+				separator = ""
+			}
+			lines = append(lines, fmt.Sprintf("  %s%s%s", entry.Prefix, ciscoPrefixRange(entry), separator))
+		}
+		lines = append(lines, "end-set")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderPrefixListJuniper(lists []prefixList) string {
+	lines := []string{"policy-options {"}
+	for _, list := range lists {
+		exact := true
+		for _, entry := range list.Entries {
+			exact = exact && entry.exact()
+		}
+
+		// Prefix lists only match exact routes, so route filter lists are
+		// used where ranges are required
+		if exact {
+			lines = append(lines, "replace:", fmt.Sprintf("  prefix-list %s {", list.Name))
+			for _, entry := range list.Entries {
+				lines = append(lines, fmt.Sprintf("    %s;", entry.Prefix))
+			}
+		} else {
+			lines = append(lines, "replace:", fmt.Sprintf("  route-filter-list %s {", list.Name))
+			for _, entry := range list.Entries {
+				bits := entry.Prefix.Maskbits()
+				switch {
+				case entry.exact():
+					lines = append(lines, fmt.Sprintf("    %s exact;", entry.Prefix))
+				case entry.Min == bits:
+					lines = append(lines, fmt.Sprintf("    %s upto /%d;", entry.Prefix, entry.Max))
+				default:
+					lines = append(lines, fmt.Sprintf("    %s prefix-length-range /%d-/%d;", entry.Prefix, entry.Min, entry.Max))
+				}
+			}
+		}
+		lines = append(lines, "  }")
+	}
+	lines = append(lines, "}")
+
+	return strings.Join(lines, "\n")
+}
+
+func renderPrefixListBIRD(lists []prefixList) string {
+	var lines []string
+	for _, list := range lists {
+		lines = append(lines, fmt.Sprintf("define %s = [", strings.ReplaceAll(list.Name, "-", "_")))
+		for i, entry := range list.Entries {
+			separator := ","
+			if i == len(list.Entries)-1 {
+				separator = ""
+			}
+
+			prefix := entry.Prefix.String()
+			if !entry.exact() {
+				prefix = fmt.Sprintf("%s{%d,%d}", prefix, entry.Min, entry.Max)
+			}
+			lines = append(lines, fmt.Sprintf("    %s%s", prefix, separator))
+		}
+		lines = append(lines, "];")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type BGPPrefixListResponseDataEntry struct {
+	Prefix string `json:"prefix"`
+	Min    int    `json:"ge"`
+	Max    int    `json:"le"`
+}
+
+type BGPPrefixListResponseDataList struct {
+	Name    string                           `json:"name"`
+	Family  int                              `json:"family,omitempty"`
+	Entries []BGPPrefixListResponseDataEntry `json:"entries"`
+}
+
+type BGPPrefixListResponseData struct {
+	Format string                          `json:"format"`
+	Lists  []BGPPrefixListResponseDataList `json:"lists"`
+	Config string                          `json:"config"`
+}
+
+func (r *BGPPrefixListResponseData) String() string {
+	return r.Config
+}
@@ -0,0 +1,143 @@
+package tool
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-sockaddr"
+)
+
+// prefixListTestEntry parses an entry in the form "prefix min max"
+func prefixListTestEntry(t *testing.T, s string) prefixListEntry {
+	t.Helper()
+
+	var prefix string
+	var min, max int
+	if _, err := fmt.Sscanf(s, "%s %d %d", &prefix, &min, &max); err != nil {
+		t.Fatalf("invalid test entry %q: %s", s, err)
+	}
+
+	addr, err := sockaddr.NewIPAddr(prefix)
+	if err != nil {
+		t.Fatalf("invalid test prefix %q: %s", prefix, err)
+	}
+
+	return prefixListEntry{Prefix: addr.Network(), Min: min, Max: max}
+}
+
+func prefixListTestStrings(entries []prefixListEntry) []string {
+	var result []string
+	for _, entry := range entries {
+		result = append(result, fmt.Sprintf("%s %d %d", entry.Prefix, entry.Min, entry.Max))
+	}
+
+	return result
+}
+
+func TestAggregatePrefixListEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []string
+	}{
+		{
+			name:     "single entry",
+			entries:  []string{"192.0.2.0/24 24 24"},
+			expected: []string{"192.0.2.0/24 24 24"},
+		},
+		{
+			name:     "duplicate entries",
+			entries:  []string{"192.0.2.0/24 24 24", "192.0.2.0/24 24 24"},
+			expected: []string{"192.0.2.0/24 24 24"},
+		},
+		{
+			name:     "more specific covered by range",
+			entries:  []string{"192.0.2.0/24 24 24", "192.0.2.0/23 23 23", "192.0.0.0/16 16 24"},
+			expected: []string{"192.0.0.0/16 16 24"},
+		},
+		{
+			name:     "more specific beyond range",
+			entries:  []string{"192.0.0.0/16 16 24", "192.0.2.128/25 25 25"},
+			expected: []string{"192.0.0.0/16 16 24", "192.0.2.128/25 25 25"},
+		},
+		{
+			name:     "exact less specific doesn't cover",
+			entries:  []string{"192.0.0.0/16 16 16", "192.0.2.0/24 24 24"},
+			expected: []string{"192.0.0.0/16 16 16", "192.0.2.0/24 24 24"},
+		},
+		{
+			name:     "siblings merged",
+			entries:  []string{"192.0.2.0/25 25 25", "192.0.2.128/25 25 25"},
+			expected: []string{"192.0.2.0/24 25 25"},
+		},
+		{
+			name:     "siblings with different ranges",
+			entries:  []string{"192.0.2.0/25 25 25", "192.0.2.128/25 25 32"},
+			expected: []string{"192.0.2.0/25 25 25", "192.0.2.128/25 25 32"},
+		},
+		{
+			name:     "non-sibling neighbours",
+			entries:  []string{"192.0.2.128/25 25 25", "192.0.3.0/25 25 25"},
+			expected: []string{"192.0.2.128/25 25 25", "192.0.3.0/25 25 25"},
+		},
+		{
+			name:     "repeated merging",
+			entries:  []string{"10.0.0.0/24 24 24", "10.0.1.0/24 24 24", "10.0.2.0/24 24 24", "10.0.3.0/24 24 24"},
+			expected: []string{"10.0.0.0/22 24 24"},
+		},
+		{
+			name:     "merged supernet covered",
+			entries:  []string{"10.0.0.0/25 25 32", "10.0.0.128/25 25 32", "10.0.0.0/24 24 32"},
+			expected: []string{"10.0.0.0/24 24 32"},
+		},
+		{
+			name:     "IPv6",
+			entries:  []string{"2001:db8::/33 33 48", "2001:db8:8000::/33 33 48", "2001:db8:1::/48 48 48"},
+			expected: []string{"2001:db8::/32 33 48"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var entries []prefixListEntry
+			for _, entry := range test.entries {
+				entries = append(entries, prefixListTestEntry(t, entry))
+			}
+
+			got := prefixListTestStrings(aggregatePrefixListEntries(entries))
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestParsePrefixListLength(t *testing.T) {
+	tests := []struct {
+		value     string
+		maxLength int
+		expected  int
+		err       bool
+	}{
+		{value: "", maxLength: 32, expected: 0},
+		{value: "24", maxLength: 32, expected: 24},
+		{value: "32", maxLength: 32, expected: 32},
+		{value: "33", maxLength: 32, err: true},
+		{value: "-1", maxLength: 32, err: true},
+		{value: "abc", maxLength: 32, err: true},
+		{value: "128", maxLength: 128, expected: 128},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%d", test.value, test.maxLength), func(t *testing.T) {
+			length, err := parsePrefixListLength(test.value, test.maxLength)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if length != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, length)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const BOGON_REFERENCE = "full bogon list"
+
+type Bogon struct {
+	registry  *util.SpecialPurposeRegistry
+	sources   []string
+	loadMutex sync.Mutex
+}
+
+func NewBogon(registry *util.SpecialPurposeRegistry, sources []string) *Bogon {
+	return &Bogon{
+		registry: registry,
+		sources:  sources,
+	}
+}
+
+func (b *Bogon) Paths() []string {
+	return []string{
+		"/bogon",
+		"/bogon/{query}",
+		"/bogon/{query}/{length:[0-9]+}",
+	}
+}
+
+func (b *Bogon) Method() string {
+	return "GET"
+}
+
+func (b *Bogon) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query, ok := vars["query"]
+	if !ok {
+		query = util.GetSourceIPAddress(r)
+	}
+
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	prefix, err := parsePrefixOrAddr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &BogonResponseData{Query: prefix.String()}
+	if prefix.Bits() == prefix.Addr().BitLen() {
+		response.Query = prefix.Addr().String()
+	}
+
+	if block, ok := b.registry.LookupPrefix(prefix); ok {
+		response.Special = true
+		response.Block = block
+	}
+
+	return NewToolResponse(response), nil
+}
+
+// Start loads the full bogon list, used across the IP tools alongside the
+// special-purpose registry
+func (b *Bogon) Start() {
+	go b.cronWork()
+}
+
+func (b *Bogon) Cron() CronSpec {
+	return CronSpec{Cron: "0 */4 * * *", Func: b.cronWork}
+}
+
+func (b *Bogon) cronWork() {
+	if !b.loadMutex.TryLock() {
+		log.Info().Msg("Bogon: Load already in progress")
+		return
+	}
+
+	log.Info().Msg("Bogon: Starting cron")
+
+	err := b.loadBogons()
+	b.loadMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Bogon: Load failed, keeping current bogons")
+		return
+	}
+
+	log.Info().Msg("Bogon: Cron completed")
+}
+
+// loadBogons loads prefixes from all sources, replacing the current list only
+// if every source loads successfully
+func (b *Bogon) loadBogons() error {
+	if len(b.sources) == 0 {
+		return errors.New("no bogon sources configured")
+	}
+
+	var prefixes []netip.Prefix
+	for _, source := range b.sources {
+		sourcePrefixes, err := b.loadSource(source)
+		if err != nil {
+			return fmt.Errorf("failed to load bogons from %s: %w", source, err)
+		}
+		prefixes = append(prefixes, sourcePrefixes...)
+	}
+
+	b.registry.SetBogons(prefixes, BOGON_REFERENCE)
+
+	log.Debug().Int("prefixes", len(prefixes)).Msg("Loaded bogons")
+	return nil
+}
+
+func (b *Bogon) loadSource(source string) ([]netip.Prefix, error) {
+	body, err := openSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			log.Warn().Err(err).Str("line", line).Msg("Skipping invalid bogon prefix")
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(prefixes) == 0 {
+		return nil, errors.New("no prefixes found")
+	}
+
+	return prefixes, nil
+}
+
+// specialPurposeError explains why an address which isn't globally reachable
+// can't be looked up, returning nil for globally reachable addresses
+func specialPurposeError(registry *util.SpecialPurposeRegistry, addr netip.Addr) error {
+	addr = addr.Unmap()
+	return specialPurposePrefixError(registry, netip.PrefixFrom(addr, addr.BitLen()))
+}
+
+func specialPurposePrefixError(registry *util.SpecialPurposeRegistry, prefix netip.Prefix) error {
+	if registry == nil {
+		return nil
+	}
+
+	block, ok := registry.LookupPrefix(prefix)
+	if !ok || block.Global {
+		return nil
+	}
+
+	if prefix.IsSingleIP() {
+		return fmt.Errorf("%s is not globally reachable: %s %s", prefix.Addr(), block.Prefix, block)
+	}
+
+	return fmt.Errorf("%s is not globally reachable: %s %s", prefix, block.Prefix, block)
+}
+
+// specialPurposeAnnotation returns a description of the special-purpose block
+// containing the prefix, if any
+func specialPurposeAnnotation(registry *util.SpecialPurposeRegistry, prefix netip.Prefix) string {
+	if registry == nil {
+		return ""
+	}
+
+	block, ok := registry.LookupPrefix(prefix)
+	if !ok {
+		return ""
+	}
+
+	return block.String()
+}
+
+type BogonResponseData struct {
+	Query   string                    `json:"query"`
+	Special bool                      `json:"special"`
+	Block   *util.SpecialPurposeBlock `json:"block,omitempty"`
+}
+
+func (r *BogonResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *BogonResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *BogonResponseData) render(colour bool) string {
+	pairs := []keyValue{
+		{Key: "Query:", Value: r.Query},
+		{Key: "Special:", Value: colourBool(r.Special, colour)},
+	}
+
+	if r.Block != nil {
+		pairs = append(pairs,
+			keyValue{Key: "Block:", Value: r.Block.Prefix.String()},
+			keyValue{Key: "Name:", Value: r.Block.Name},
+			keyValue{Key: "Reference:", Value: r.Block.Reference},
+			keyValue{Key: "Global:", Value: colourBool(r.Block.Global, colour)},
+		)
+	}
+
+	return renderKeyValues(12, colour, pairs...)
+}
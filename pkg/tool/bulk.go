@@ -0,0 +1,230 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const BULK_MAX_QUERIES = 1000
+const BULK_MAX_BODY_SIZE = 1 << 20
+const BULK_CONCURRENCY = 10
+
+type BulkLookupFunc func(query string) (ToolResponseData, error)
+
+func parseBulkQueries(r *http.Request) ([]string, error) {
+	if r.Body == nil {
+		return nil, errors.New("missing queries")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, BULK_MAX_BODY_SIZE))
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, errors.New("failed to read queries")
+	}
+	r.Body.Close()
+
+	body = bytes.TrimSpace(body)
+
+	var queries []string
+	if len(body) > 0 && body[0] == '[' {
+		var parsed []string
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, errors.New("failed to parse queries - expected JSON array of strings")
+		}
+		for _, query := range parsed {
+			if query = strings.TrimSpace(query); query != "" {
+				queries = append(queries, query)
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			if query := strings.TrimSpace(scanner.Text()); query != "" {
+				queries = append(queries, query)
+			}
+		}
+	}
+
+	if len(queries) == 0 {
+		return nil, errors.New("missing queries")
+	}
+
+	if len(queries) > BULK_MAX_QUERIES {
+		return nil, fmt.Errorf("too many queries - maximum is %d", BULK_MAX_QUERIES)
+	}
+
+	return queries, nil
+}
+
+func runBulk(queries []string, lookup BulkLookupFunc) *BulkResponseData {
+	results := make(BulkResponseData, 0, len(queries))
+	streamBulk(queries, lookup, func(data ToolResponseData) error {
+		results = append(results, *data.(*BulkResponseDataItem))
+		return nil
+	})
+
+	return &results
+}
+
+// streamBulk runs lookups concurrently, emitting results in input order as
+// soon as each becomes available
+func streamBulk(queries []string, lookup BulkLookupFunc, emit ToolStreamFunc) error {
+	ready := make([]chan BulkResponseDataItem, len(queries))
+	for i := range ready {
+		ready[i] = make(chan BulkResponseDataItem, 1)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		sem := make(chan struct{}, BULK_CONCURRENCY)
+		for i, query := range queries {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			go func(i int, query string) {
+				defer func() { <-sem }()
+
+				item := BulkResponseDataItem{Query: query}
+				data, err := lookup(query)
+				if err != nil {
+					item.Error = err.Error()
+				} else {
+					item.Result = data
+				}
+				ready[i] <- item
+			}(i, query)
+		}
+	}()
+
+	for i := range queries {
+		item := <-ready[i]
+		if err := emit(&item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type BulkResponseData []BulkResponseDataItem
+
+type BulkResponseDataItem struct {
+	Query  string           `json:"query"`
+	Result ToolResponseData `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+func (r *BulkResponseDataItem) String() string {
+	return r.render(false)
+}
+
+func (r *BulkResponseDataItem) ColourString() string {
+	return r.render(true)
+}
+
+func (r *BulkResponseDataItem) render(colour bool) string {
+	query := colourise(r.Query, ANSI_BOLD_CYAN, colour)
+	if r.Error != "" {
+		return fmt.Sprintf("%s\t%s", query, colourise("error: "+r.Error, ANSI_RED, colour))
+	}
+
+	table, ok := r.Result.(ToolResponseDataTable)
+	if !ok {
+		return fmt.Sprintf("%s\t%s", query, r.Result.String())
+	}
+
+	var lines []string
+	for _, row := range table.Rows() {
+		lines = append(lines, query+"\t"+strings.Join(row, "\t"))
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("%s\t%s", query, colourise("error: no results", ANSI_RED, colour))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (r *BulkResponseData) Items() []interface{} {
+	items := make([]interface{}, len(*r))
+	for i, item := range *r {
+		items[i] = item
+	}
+
+	return items
+}
+
+// Header uses the widest header of all results, as tables may include
+// optional trailing columns depending on their content
+func (r *BulkResponseData) Header() []string {
+	var header []string
+	for _, item := range *r {
+		if table, ok := item.Result.(ToolResponseDataTable); ok && len(table.Header()) > len(header) {
+			header = table.Header()
+		}
+	}
+
+	if header == nil {
+		return []string{"query", "result", "error"}
+	}
+
+	return append(append([]string{"query"}, header...), "error")
+}
+
+func (r *BulkResponseData) Rows() [][]string {
+	header := r.Header()
+
+	var rows [][]string
+	for _, item := range *r {
+		if item.Error != "" {
+			rows = append(rows, bulkRow(len(header), item.Query, nil, item.Error))
+			continue
+		}
+
+		table, ok := item.Result.(ToolResponseDataTable)
+		if !ok {
+			rows = append(rows, bulkRow(len(header), item.Query, []string{item.Result.String()}, ""))
+			continue
+		}
+
+		tableRows := table.Rows()
+		if len(tableRows) == 0 {
+			rows = append(rows, bulkRow(len(header), item.Query, nil, "no results"))
+			continue
+		}
+
+		for _, row := range tableRows {
+			rows = append(rows, bulkRow(len(header), item.Query, row, ""))
+		}
+	}
+
+	return rows
+}
+
+func bulkRow(width int, query string, cells []string, err string) []string {
+	row := make([]string, width)
+	row[0] = query
+	copy(row[1:width-1], cells)
+	row[width-1] = err
+
+	return row
+}
+
+func (r *BulkResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *BulkResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
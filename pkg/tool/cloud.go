@@ -0,0 +1,510 @@
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CLOUD_FORMAT_AWS        = "aws"
+	CLOUD_FORMAT_GCP        = "gcp"
+	CLOUD_FORMAT_AZURE      = "azure"
+	CLOUD_FORMAT_CLOUDFLARE = "cloudflare"
+	CLOUD_FORMAT_FASTLY     = "fastly"
+	CLOUD_FORMAT_ORACLE     = "oracle"
+)
+
+// CloudSource is a provider's published IP range file, parsed according to
+// its format
+type CloudSource struct {
+	Format   string
+	Location string
+}
+
+type cloudParser func(r io.Reader) ([]CloudRange, error)
+
+var cloudParsers = map[string]cloudParser{
+	CLOUD_FORMAT_AWS:        parseCloudAWS,
+	CLOUD_FORMAT_GCP:        parseCloudGCP,
+	CLOUD_FORMAT_AZURE:      parseCloudAzure,
+	CLOUD_FORMAT_CLOUDFLARE: parseCloudCloudflare,
+	CLOUD_FORMAT_FASTLY:     parseCloudFastly,
+	CLOUD_FORMAT_ORACLE:     parseCloudOracle,
+}
+
+type CloudRange struct {
+	Prefix   netip.Prefix `json:"prefix"`
+	Provider string       `json:"provider"`
+	Service  string       `json:"service,omitempty"`
+	Region   string       `json:"region,omitempty"`
+}
+
+type cloudIndex struct {
+	trie     *prefixtrie.Trie[CloudRange]
+	count    int
+	loadedAt time.Time
+}
+
+func newCloudIndex(ranges []CloudRange) *cloudIndex {
+	index := &cloudIndex{
+		trie:     prefixtrie.New[CloudRange](),
+		loadedAt: time.Now().UTC(),
+	}
+
+	for _, r := range ranges {
+		index.trie.Insert(r.Prefix, r)
+		index.count++
+	}
+
+	return index
+}
+
+// lookup returns all ranges equal to or containing the prefix, most specific
+// first
+func (i *cloudIndex) lookup(prefix netip.Prefix) []CloudRange {
+	matches := i.trie.Covering(prefix)
+
+	var ranges []CloudRange
+	for m := len(matches) - 1; m >= 0; m-- {
+		for _, r := range matches[m].Values {
+			if !slices.Contains(ranges, r) {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+
+	return ranges
+}
+
+// annotate describes the provider, services and region of the most specific
+// ranges containing the prefix. Prefixes only partially covered by provider
+// ranges, such as aggregate BGP routes, are described by provider alone
+func (i *cloudIndex) annotate(prefix netip.Prefix) string {
+	ranges := i.lookup(prefix)
+	if len(ranges) == 0 {
+		var providers []string
+		for _, match := range i.trie.CoveredBy(prefix) {
+			for _, r := range match.Values {
+				if !slices.Contains(providers, r.Provider) {
+					providers = append(providers, r.Provider)
+				}
+			}
+		}
+
+		if len(providers) == 0 {
+			return ""
+		}
+
+		return strings.Join(providers, ", ") + " (partial)"
+	}
+
+	var services, regions []string
+	for _, r := range ranges {
+		if r.Prefix != ranges[0].Prefix || r.Provider != ranges[0].Provider {
+			break
+		}
+		if r.Service != "" && !slices.Contains(services, r.Service) {
+			services = append(services, r.Service)
+		}
+		if r.Region != "" && !slices.Contains(regions, r.Region) {
+			regions = append(regions, r.Region)
+		}
+	}
+
+	tag := []string{ranges[0].Provider}
+	if len(services) > 0 {
+		tag = append(tag, strings.Join(services, ","))
+	}
+	if len(regions) > 0 {
+		tag = append(tag, strings.Join(regions, ","))
+	}
+
+	return strings.Join(tag, " ")
+}
+
+type Cloud struct {
+	sources    []CloudSource
+	index      atomic.Pointer[cloudIndex]
+	indexMutex sync.Mutex
+}
+
+func NewCloud(sources []CloudSource) *Cloud {
+	return &Cloud{sources: sources}
+}
+
+func (c *Cloud) Paths() []string {
+	return []string{
+		"/cloud",
+		"/cloud/{query}",
+		"/cloud/{query}/{length:[0-9]+}",
+	}
+}
+
+func (c *Cloud) Method() string {
+	return "GET"
+}
+
+func (c *Cloud) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query, ok := vars["query"]
+	if !ok {
+		query = util.GetSourceIPAddress(r)
+	}
+
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	prefix, err := parsePrefixOrAddr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := c.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CloudResponseData{
+		Query:    prefix.String(),
+		Cloud:    index.annotate(prefix),
+		Ranges:   index.lookup(prefix),
+		LoadedAt: index.loadedAt,
+	}
+	if prefix.IsSingleIP() {
+		response.Query = prefix.Addr().String()
+	}
+	if response.Ranges == nil {
+		response.Ranges = []CloudRange{}
+	}
+
+	return NewToolResponse(response), nil
+}
+
+// Start loads the ranges used to tag BGP and GeoIP results
+func (c *Cloud) Start() {
+	go c.cronWork()
+}
+
+func (c *Cloud) Cron() CronSpec {
+	return CronSpec{Cron: "30 */6 * * *", Func: c.cronWork}
+}
+
+func (c *Cloud) cronWork() {
+	if !c.indexMutex.TryLock() {
+		log.Info().Msg("Cloud: Load already in progress")
+		return
+	}
+
+	log.Info().Msg("Cloud: Starting cron")
+
+	err := c.loadIndex()
+	c.indexMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Cloud: Load failed, keeping current ranges")
+		return
+	}
+
+	log.Info().Msg("Cloud: Cron completed")
+}
+
+// annotate describes the cloud ranges containing the prefix, or returns an
+// empty string where no ranges have been loaded
+func (c *Cloud) annotate(prefix netip.Prefix) string {
+	index := c.index.Load()
+	if index == nil {
+		return ""
+	}
+
+	return index.annotate(prefix)
+}
+
+func (c *Cloud) getIndex() (*cloudIndex, error) {
+	if index := c.index.Load(); index != nil {
+		return index, nil
+	}
+
+	c.indexMutex.Lock()
+	defer c.indexMutex.Unlock()
+
+	if index := c.index.Load(); index != nil {
+		return index, nil
+	}
+
+	err := c.loadIndex()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load cloud ranges")
+		return nil, errors.New("cloud range data unavailable")
+	}
+
+	return c.index.Load(), nil
+}
+
+// loadIndex loads ranges from all sources, replacing the current index only if
+// every source loads successfully
+func (c *Cloud) loadIndex() error {
+	if len(c.sources) == 0 {
+		return errors.New("no cloud sources configured")
+	}
+
+	var ranges []CloudRange
+	for _, source := range c.sources {
+		sourceRanges, err := c.loadSource(source)
+		if err != nil {
+			return fmt.Errorf("failed to load cloud ranges from %s: %w", source.Location, err)
+		}
+		ranges = append(ranges, sourceRanges...)
+	}
+
+	index := newCloudIndex(ranges)
+	c.index.Store(index)
+
+	log.Debug().Int("ranges", index.count).Msg("Loaded cloud ranges")
+	return nil
+}
+
+func (c *Cloud) loadSource(source CloudSource) ([]CloudRange, error) {
+	parser, ok := cloudParsers[strings.ToLower(source.Format)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s", source.Format)
+	}
+
+	body, err := openSource(source.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	ranges, err := parser(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("no ranges found")
+	}
+
+	return ranges, nil
+}
+
+// appendCloudRange appends the range if its prefix is valid
+func appendCloudRange(ranges []CloudRange, prefix string, provider string, service string, region string) []CloudRange {
+	parsed, err := netip.ParsePrefix(strings.TrimSpace(prefix))
+	if err != nil {
+		log.Warn().Err(err).Str("prefix", prefix).Str("provider", provider).Msg("Skipping invalid cloud range")
+		return ranges
+	}
+
+	return append(ranges, CloudRange{
+		Prefix:   parsed.Masked(),
+		Provider: provider,
+		Service:  service,
+		Region:   region,
+	})
+}
+
+func parseCloudAWS(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Prefixes []struct {
+			Prefix  string `json:"ip_prefix"`
+			Region  string `json:"region"`
+			Service string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			Prefix  string `json:"ipv6_prefix"`
+			Region  string `json:"region"`
+			Service string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, p := range data.Prefixes {
+		ranges = appendCloudRange(ranges, p.Prefix, "AWS", p.Service, p.Region)
+	}
+	for _, p := range data.IPv6Prefixes {
+		ranges = appendCloudRange(ranges, p.Prefix, "AWS", p.Service, p.Region)
+	}
+
+	return ranges, nil
+}
+
+func parseCloudGCP(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Service    string `json:"service"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, p := range data.Prefixes {
+		prefix := p.IPv4Prefix
+		if prefix == "" {
+			prefix = p.IPv6Prefix
+		}
+		ranges = appendCloudRange(ranges, prefix, "GCP", p.Service, p.Scope)
+	}
+
+	return ranges, nil
+}
+
+// parseCloudAzure parses the Azure service tags file. Services are named by
+// their system service, falling back to the tag name without region
+func parseCloudAzure(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, v := range data.Values {
+		service := v.Properties.SystemService
+		if service == "" {
+			service, _, _ = strings.Cut(v.Name, ".")
+		}
+
+		for _, prefix := range v.Properties.AddressPrefixes {
+			ranges = appendCloudRange(ranges, prefix, "Azure", service, v.Properties.Region)
+		}
+	}
+
+	return ranges, nil
+}
+
+func parseCloudCloudflare(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Result struct {
+			IPv4CIDRs []string `json:"ipv4_cidrs"`
+			IPv6CIDRs []string `json:"ipv6_cidrs"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, prefix := range append(data.Result.IPv4CIDRs, data.Result.IPv6CIDRs...) {
+		ranges = appendCloudRange(ranges, prefix, "Cloudflare", "CDN", "")
+	}
+
+	return ranges, nil
+}
+
+func parseCloudFastly(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Addresses     []string `json:"addresses"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, prefix := range append(data.Addresses, data.IPv6Addresses...) {
+		ranges = appendCloudRange(ranges, prefix, "Fastly", "CDN", "")
+	}
+
+	return ranges, nil
+}
+
+func parseCloudOracle(r io.Reader) ([]CloudRange, error) {
+	var data struct {
+		Regions []struct {
+			Region string `json:"region"`
+			CIDRs  []struct {
+				CIDR string   `json:"cidr"`
+				Tags []string `json:"tags"`
+			} `json:"cidrs"`
+		} `json:"regions"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var ranges []CloudRange
+	for _, region := range data.Regions {
+		for _, cidr := range region.CIDRs {
+			ranges = appendCloudRange(ranges, cidr.CIDR, "Oracle", strings.Join(cidr.Tags, ","), region.Region)
+		}
+	}
+
+	return ranges, nil
+}
+
+type CloudResponseData struct {
+	Query    string       `json:"query"`
+	Cloud    string       `json:"cloud,omitempty"`
+	Ranges   []CloudRange `json:"ranges"`
+	LoadedAt time.Time    `json:"loaded_at"`
+}
+
+func (r *CloudResponseData) Header() []string {
+	return []string{"prefix", "provider", "service", "region"}
+}
+
+func (r *CloudResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, cloudRange := range r.Ranges {
+		rows = append(rows, []string{cloudRange.Prefix.String(), cloudRange.Provider, cloudRange.Service, cloudRange.Region})
+	}
+
+	return rows
+}
+
+func (r *CloudResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *CloudResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *CloudResponseData) render(colour bool) string {
+	cloud := r.Cloud
+	if cloud == "" {
+		cloud = colourise("not a known cloud or CDN range", ANSI_YELLOW, colour)
+	}
+
+	output := renderKeyValues(8, colour,
+		keyValue{Key: "Query:", Value: r.Query},
+		keyValue{Key: "Cloud:", Value: cloud},
+	)
+	if len(r.Ranges) == 0 {
+		return output
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
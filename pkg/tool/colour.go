@@ -0,0 +1,85 @@
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+const (
+	ANSI_RESET     = "\x1b[0m"
+	ANSI_BOLD      = "\x1b[1m"
+	ANSI_RED       = "\x1b[31m"
+	ANSI_GREEN     = "\x1b[32m"
+	ANSI_YELLOW    = "\x1b[33m"
+	ANSI_BOLD_CYAN = "\x1b[1;36m"
+)
+
+func colourise(s string, code string, colour bool) string {
+	if !colour || s == "" {
+		return s
+	}
+
+	return code + s + ANSI_RESET
+}
+
+type keyValue struct {
+	Key   string
+	Value string
+}
+
+func renderKeyValues(width int, colour bool, pairs ...keyValue) string {
+	var lines []string
+	for _, pair := range pairs {
+		padding := ""
+		if len(pair.Key) < width {
+			padding = strings.Repeat(" ", width-len(pair.Key))
+		}
+		lines = append(lines, colourise(pair.Key, ANSI_BOLD_CYAN, colour)+padding+pair.Value)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderTable(header []string, rows [][]string, colour bool) string {
+	output := new(bytes.Buffer)
+
+	// Header auto formatting mangles escape sequences and splits digits from
+	// words (e.g. "IPV 4"), so headers are formatted prior to colouring
+	table := tablewriter.NewTable(output, tablewriter.WithHeaderAutoFormat(tw.Off))
+	var formattedHeader []string
+	for _, h := range header {
+		formattedHeader = append(formattedHeader, colourise(strings.ToUpper(strings.ReplaceAll(h, "_", " ")), ANSI_BOLD_CYAN, colour))
+	}
+	table.Header(formattedHeader)
+
+	for _, row := range rows {
+		table.Append(row)
+	}
+	table.Render()
+
+	return output.String()
+}
+
+func renderTableData(data ToolResponseDataTable, colour bool) string {
+	return renderTable(data.Header(), data.Rows(), colour)
+}
+
+func colourBool(b bool, colour bool) string {
+	if b {
+		return colourise(fmt.Sprintf("%t", b), ANSI_GREEN, colour)
+	}
+
+	return colourise(fmt.Sprintf("%t", b), ANSI_RED, colour)
+}
+
+func checkMark(b bool) string {
+	if b {
+		return "✔"
+	}
+
+	return ""
+}
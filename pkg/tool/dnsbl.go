@@ -0,0 +1,426 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DNSBL_TYPE_IP     = "ip"
+	DNSBL_TYPE_DOMAIN = "domain"
+)
+
+const (
+	DNSBL_STATUS_LISTED     = "listed"
+	DNSBL_STATUS_NOT_LISTED = "not-listed"
+	DNSBL_STATUS_SKIPPED    = "skipped"
+	DNSBL_STATUS_ERROR      = "error"
+)
+
+const DNSBL_DEFAULT_TIMEOUT = 5 * time.Second
+
+// DNSBLZone is a DNS blocklist zone, queried by reversed IP address or, for
+// domain zones (RHSBLs), by host name
+type DNSBLZone struct {
+	Zone string
+	Type string
+}
+
+type dnsblCode struct {
+	Reason string
+	Error  bool
+}
+
+var dnsblSpamhausErrorCodes = map[string]dnsblCode{
+	"127.255.255.252": {Reason: "Typing error in DNSBL name", Error: true},
+	"127.255.255.254": {Reason: "Query via public/open resolver refused", Error: true},
+	"127.255.255.255": {Reason: "Excessive number of queries", Error: true},
+}
+
+// dnsblCodes decodes the return codes of well known zones. Listings in other
+// zones are described by their TXT record
+var dnsblCodes = map[string]map[string]dnsblCode{
+	"zen.spamhaus.org": {
+		"127.0.0.2":  {Reason: "Spamhaus SBL - direct spam source"},
+		"127.0.0.3":  {Reason: "Spamhaus SBL CSS - snowshoe spam source"},
+		"127.0.0.4":  {Reason: "Spamhaus XBL - exploited or infected host"},
+		"127.0.0.9":  {Reason: "Spamhaus DROP - hijacked or leased to spammers"},
+		"127.0.0.10": {Reason: "Spamhaus PBL - ISP policy, should not send direct mail"},
+		"127.0.0.11": {Reason: "Spamhaus PBL - Spamhaus policy, should not send direct mail"},
+	},
+	"sbl.spamhaus.org": {
+		"127.0.0.2": {Reason: "Spamhaus SBL - direct spam source"},
+		"127.0.0.3": {Reason: "Spamhaus SBL CSS - snowshoe spam source"},
+		"127.0.0.9": {Reason: "Spamhaus DROP - hijacked or leased to spammers"},
+	},
+	"xbl.spamhaus.org": {
+		"127.0.0.4": {Reason: "Spamhaus XBL - exploited or infected host"},
+	},
+	"pbl.spamhaus.org": {
+		"127.0.0.10": {Reason: "Spamhaus PBL - ISP policy, should not send direct mail"},
+		"127.0.0.11": {Reason: "Spamhaus PBL - Spamhaus policy, should not send direct mail"},
+	},
+	"dbl.spamhaus.org": {
+		"127.0.1.2":   {Reason: "Spamhaus DBL - spam domain"},
+		"127.0.1.4":   {Reason: "Spamhaus DBL - phishing domain"},
+		"127.0.1.5":   {Reason: "Spamhaus DBL - malware domain"},
+		"127.0.1.6":   {Reason: "Spamhaus DBL - botnet C&C domain"},
+		"127.0.1.102": {Reason: "Spamhaus DBL - abused legitimate spam"},
+		"127.0.1.103": {Reason: "Spamhaus DBL - abused spammed redirector"},
+		"127.0.1.104": {Reason: "Spamhaus DBL - abused legitimate phishing"},
+		"127.0.1.105": {Reason: "Spamhaus DBL - abused legitimate malware"},
+		"127.0.1.106": {Reason: "Spamhaus DBL - abused legitimate botnet C&C"},
+	},
+	"b.barracudacentral.org": {
+		"127.0.0.2": {Reason: "Barracuda Reputation Block List - poor reputation"},
+	},
+	"bl.spamcop.net": {
+		"127.0.0.2": {Reason: "SpamCop - reported spam source"},
+	},
+	"psbl.surriel.com": {
+		"127.0.0.2": {Reason: "PSBL - spam trap hit"},
+	},
+	"bl.mailspike.net": {
+		"127.0.0.2": {Reason: "Mailspike - spam source"},
+	},
+	"rep.mailspike.net": {
+		"127.0.0.10": {Reason: "Mailspike - worst possible reputation"},
+		"127.0.0.11": {Reason: "Mailspike - very bad reputation"},
+		"127.0.0.12": {Reason: "Mailspike - bad reputation"},
+		"127.0.0.13": {Reason: "Mailspike - suspicious reputation"},
+		"127.0.0.14": {Reason: "Mailspike - neutral, probably spam"},
+	},
+}
+
+type DNSBL struct {
+	zones          []DNSBLZone
+	resolvers      []*net.Resolver
+	timeout        time.Duration
+	specialPurpose *util.SpecialPurposeRegistry
+}
+
+// NewDNSBL creates a DNSBL tool querying the zones via the given resolvers
+// (host or host:port), or the system resolver if none are given. The timeout
+// applies to each attempt, with failed lookups retried against the next
+// resolver
+func NewDNSBL(zones []DNSBLZone, resolvers []string, timeout time.Duration) *DNSBL {
+	if timeout <= 0 {
+		timeout = DNSBL_DEFAULT_TIMEOUT
+	}
+
+	return &DNSBL{
+		zones:     zones,
+		resolvers: newDNSBLResolvers(resolvers),
+		timeout:   timeout,
+	}
+}
+
+// WithSpecialPurpose short-circuits lookups for addresses which aren't
+// globally reachable
+func (d *DNSBL) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *DNSBL {
+	d.specialPurpose = registry
+	return d
+}
+
+// newDNSBLResolvers returns a resolver for each of the given resolvers
+func newDNSBLResolvers(resolvers []string) []*net.Resolver {
+	if len(resolvers) == 0 {
+		return []*net.Resolver{net.DefaultResolver}
+	}
+
+	var dnsResolvers []*net.Resolver
+	for _, resolver := range resolvers {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			resolver = net.JoinHostPort(resolver, "53")
+		}
+
+		dnsResolvers = append(dnsResolvers, &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, resolver)
+			},
+		})
+	}
+
+	return dnsResolvers
+}
+
+// dnsblLookup tries each resolver in turn until one answers, treating a name
+// not being found as an answer
+func dnsblLookup[T any](ctx context.Context, d *DNSBL, lookup func(ctx context.Context, resolver *net.Resolver) (T, error)) (T, error) {
+	var result T
+	var err error
+	for _, resolver := range d.resolvers {
+		attemptCtx, cancel := context.WithTimeout(ctx, d.timeout)
+		result, err = lookup(attemptCtx, resolver)
+		cancel()
+
+		var dnsErr *net.DNSError
+		if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) || ctx.Err() != nil {
+			return result, err
+		}
+	}
+
+	return result, err
+}
+
+func (d *DNSBL) lookupNetIP(ctx context.Context, network string, host string) ([]netip.Addr, error) {
+	return dnsblLookup(ctx, d, func(ctx context.Context, resolver *net.Resolver) ([]netip.Addr, error) {
+		return resolver.LookupNetIP(ctx, network, host)
+	})
+}
+
+func (d *DNSBL) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	return dnsblLookup(ctx, d, func(ctx context.Context, resolver *net.Resolver) ([]string, error) {
+		return resolver.LookupTXT(ctx, name)
+	})
+}
+
+func (d *DNSBL) Paths() []string {
+	return []string{
+		"/dnsbl",
+		"/dnsbl/{query}",
+	}
+}
+
+func (d *DNSBL) Method() string {
+	return "GET"
+}
+
+func (d *DNSBL) Handle(r *http.Request) (*ToolResponse, error) {
+	if len(d.zones) == 0 {
+		return nil, errors.New("no DNSBL zones configured")
+	}
+
+	vars := mux.Vars(r)
+
+	query, ok := vars["query"]
+	if !ok {
+		query = util.GetSourceIPAddress(r)
+	}
+	query = strings.TrimSuffix(strings.ToLower(query), ".")
+
+	response := &DNSBLResponseData{Query: query}
+
+	addr, err := netip.ParseAddr(query)
+	if err != nil {
+		response.Host = query
+
+		addrs, err := d.lookupNetIP(r.Context(), "ip", query)
+		if err != nil {
+			log.Error().Err(err).Send()
+			return nil, errors.New("failed to lookup host")
+		}
+		if len(addrs) == 0 {
+			return nil, errors.New("failed to lookup host - no DNS records")
+		}
+		addr = addrs[0]
+	}
+	addr = addr.Unmap()
+	response.Address = addr.String()
+
+	if err := specialPurposeError(d.specialPurpose, addr); err != nil {
+		return nil, err
+	}
+
+	response.Results = make([]DNSBLResponseDataItem, len(d.zones))
+
+	var wg sync.WaitGroup
+	for i, zone := range d.zones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response.Results[i] = d.check(r.Context(), zone, addr, response.Host)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range response.Results {
+		if result.Status == DNSBL_STATUS_LISTED {
+			response.Listed++
+		}
+	}
+
+	return NewToolResponse(response), nil
+}
+
+// check queries a single zone, by reversed address for IP zones or by host
+// name for domain zones
+func (d *DNSBL) check(ctx context.Context, zone DNSBLZone, addr netip.Addr, host string) DNSBLResponseDataItem {
+	result := DNSBLResponseDataItem{Zone: zone.Zone, Type: zone.Type}
+
+	if zone.Type == DNSBL_TYPE_DOMAIN {
+		if host == "" {
+			result.Status = DNSBL_STATUS_SKIPPED
+			result.Reasons = []string{"domain zone requires a host name"}
+			return result
+		}
+		result.Name = host + "." + zone.Zone
+	} else {
+		result.Name = dnsblReverse(addr) + "." + zone.Zone
+	}
+
+	start := time.Now()
+	addrs, err := d.lookupNetIP(ctx, "ip4", result.Name)
+	result.Duration = time.Since(start).Seconds() * 1000
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		result.Status = DNSBL_STATUS_NOT_LISTED
+		return result
+	}
+	if err != nil {
+		result.Status = DNSBL_STATUS_ERROR
+		result.Reasons = []string{err.Error()}
+		return result
+	}
+
+	result.Status = DNSBL_STATUS_LISTED
+	for _, listed := range addrs {
+		result.Codes = append(result.Codes, listed.String())
+
+		code, err := dnsblDecode(zone.Zone, listed)
+		if err != nil {
+			result.Status = DNSBL_STATUS_ERROR
+			result.Reasons = append(result.Reasons, err.Error())
+			continue
+		}
+		if code.Error {
+			result.Status = DNSBL_STATUS_ERROR
+		}
+		if code.Reason != "" {
+			result.Reasons = append(result.Reasons, code.Reason)
+		}
+	}
+
+	// Fall back to the zone's own explanation for unknown codes
+	if result.Status == DNSBL_STATUS_LISTED && len(result.Reasons) == 0 {
+		txt, err := d.lookupTXT(ctx, result.Name)
+		if err == nil {
+			result.Reasons = txt
+		}
+	}
+	result.Duration = time.Since(start).Seconds() * 1000
+
+	return result
+}
+
+// dnsblDecode decodes a return code, which must be within 127.0.0.0/8
+func dnsblDecode(zone string, code netip.Addr) (dnsblCode, error) {
+	if !code.Is4() || code.As4()[0] != 127 {
+		return dnsblCode{}, fmt.Errorf("unexpected return code %s", code)
+	}
+
+	if strings.HasSuffix(zone, ".spamhaus.org") {
+		if decoded, ok := dnsblSpamhausErrorCodes[code.String()]; ok {
+			return decoded, nil
+		}
+	}
+
+	return dnsblCodes[zone][code.String()], nil
+}
+
+// dnsblReverse returns the address in DNSBL query order, by octet for IPv4
+// and by nibble for IPv6
+func dnsblReverse(addr netip.Addr) string {
+	var labels []string
+	if addr.Is4() {
+		b := addr.As4()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(b[i]))
+		}
+	} else {
+		b := addr.As16()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%x", b[i]&0x0f), fmt.Sprintf("%x", b[i]>>4))
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+type DNSBLResponseDataItem struct {
+	Zone     string   `json:"zone"`
+	Type     string   `json:"type"`
+	Name     string   `json:"name,omitempty"`
+	Status   string   `json:"status"`
+	Codes    []string `json:"codes,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Duration float64  `json:"duration_ms"`
+}
+
+type DNSBLResponseData struct {
+	Query   string                  `json:"query"`
+	Host    string                  `json:"host,omitempty"`
+	Address string                  `json:"address"`
+	Listed  int                     `json:"listed"`
+	Results []DNSBLResponseDataItem `json:"results"`
+}
+
+func (r *DNSBLResponseData) Header() []string {
+	return []string{"zone", "status", "codes", "reasons", "time"}
+}
+
+func (r *DNSBLResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, result := range r.Results {
+		rows = append(rows, []string{
+			result.Zone,
+			result.Status,
+			strings.Join(result.Codes, ", "),
+			strings.Join(result.Reasons, "; "),
+			fmt.Sprintf("%.0fms", result.Duration),
+		})
+	}
+
+	return rows
+}
+
+func (r *DNSBLResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *DNSBLResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *DNSBLResponseData) render(colour bool) string {
+	pairs := []keyValue{{Key: "Query:", Value: r.Query}}
+	if r.Host != "" {
+		pairs = append(pairs, keyValue{Key: "Address:", Value: r.Address})
+	}
+
+	listed := colourise(fmt.Sprintf("%d of %d", r.Listed, len(r.Results)), ANSI_GREEN, colour)
+	if r.Listed > 0 {
+		listed = colourise(fmt.Sprintf("%d of %d", r.Listed, len(r.Results)), ANSI_RED, colour)
+	}
+	pairs = append(pairs, keyValue{Key: "Listed:", Value: listed})
+
+	rows := r.Rows()
+	if colour {
+		for i, result := range r.Results {
+			switch result.Status {
+			case DNSBL_STATUS_LISTED:
+				rows[i][1] = colourise(rows[i][1], ANSI_RED, colour)
+			case DNSBL_STATUS_NOT_LISTED:
+				rows[i][1] = colourise(rows[i][1], ANSI_GREEN, colour)
+			case DNSBL_STATUS_ERROR:
+				rows[i][1] = colourise(rows[i][1], ANSI_YELLOW, colour)
+			}
+		}
+	}
+
+	return renderKeyValues(10, colour, pairs...) + "\n\n" + renderTable(r.Header(), rows, colour)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 
 	"github.com/0x4c6565/lee.io/internal/pkg/util"
 	"github.com/gorilla/mux"
@@ -29,13 +30,29 @@ func (r *GeoIP2FileSystemReader) Open() (*geoip2.Reader, error) {
 }
 
 type GeoIP struct {
-	reader GeoIPReader
+	reader         GeoIPReader
+	specialPurpose *util.SpecialPurposeRegistry
+	cloud          *Cloud
 }
 
 func NewGeoIP(reader GeoIPReader) *GeoIP {
 	return &GeoIP{reader: reader}
 }
 
+// WithSpecialPurpose short-circuits lookups for addresses which aren't
+// globally reachable, and annotates other special-purpose addresses
+func (g *GeoIP) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *GeoIP {
+	g.specialPurpose = registry
+	return g
+}
+
+// WithCloud tags results with the cloud or CDN provider range containing the
+// address
+func (g *GeoIP) WithCloud(cloud *Cloud) *GeoIP {
+	g.cloud = cloud
+	return g
+}
+
 func (g *GeoIP) Paths() []string {
 	return []string{
 		"/geoip",
@@ -48,12 +65,70 @@ func (g *GeoIP) Method() string {
 }
 
 func (g *GeoIP) Handle(r *http.Request) (*ToolResponse, error) {
+	db, err := g.reader.Open()
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, errors.New("failed to open GeoIP database")
+	}
+	defer db.Close()
+
+	if r.Method == http.MethodPost {
+		queries, err := parseBulkQueries(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewToolResponse(runBulk(queries, func(query string) (ToolResponseData, error) {
+			return g.lookup(db, query)
+		})), nil
+	}
+
 	vars := mux.Vars(r)
 
-	ip := net.ParseIP(util.GetSourceIPAddress(r))
-	hostVar, ok := vars["host"]
-	if ok {
-		lookupResp, err := net.LookupIP(hostVar)
+	host, ok := vars["host"]
+	if !ok {
+		host = util.GetSourceIPAddress(r)
+	}
+
+	response, err := g.lookup(db, host)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewToolResponse(response), nil
+}
+
+func (g *GeoIP) HandleStream(r *http.Request, emit ToolStreamFunc) error {
+	if r.Method != http.MethodPost {
+		response, err := g.Handle(r)
+		if err != nil {
+			return err
+		}
+
+		return emit(response.Data)
+	}
+
+	queries, err := parseBulkQueries(r)
+	if err != nil {
+		return err
+	}
+
+	db, err := g.reader.Open()
+	if err != nil {
+		log.Error().Err(err).Send()
+		return errors.New("failed to open GeoIP database")
+	}
+	defer db.Close()
+
+	return streamBulk(queries, func(query string) (ToolResponseData, error) {
+		return g.lookup(db, query)
+	}, emit)
+}
+
+func (g *GeoIP) lookup(db *geoip2.Reader, host string) (*GeoIPResponseData, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		lookupResp, err := net.LookupIP(host)
 		if err != nil {
 			log.Error().Err(err).Send()
 			return nil, errors.New("failed to lookup host")
@@ -64,12 +139,10 @@ func (g *GeoIP) Handle(r *http.Request) (*ToolResponse, error) {
 		ip = lookupResp[0]
 	}
 
-	db, err := g.reader.Open()
-	if err != nil {
-		log.Error().Err(err).Send()
-		return nil, errors.New("failed to open GeoIP database")
+	addr, _ := netip.AddrFromSlice(ip)
+	if err := specialPurposeError(g.specialPurpose, addr); err != nil {
+		return nil, err
 	}
-	defer db.Close()
 
 	record, err := db.City(ip)
 	if err != nil {
@@ -77,18 +150,23 @@ func (g *GeoIP) Handle(r *http.Request) (*ToolResponse, error) {
 		return nil, errors.New("failed to lookup GeoIP host")
 	}
 
-	return NewToolResponse(
-		&GeoIPResponseData{
-			Address:     ip.String(),
-			Country:     record.Country.Names["en"],
-			CountryCode: record.Country.IsoCode,
-			City:        record.City.Names["en"],
-			Postcode:    record.Postal.Code,
-			Timezone:    record.Location.TimeZone,
-			Longitude:   record.Location.Longitude,
-			Latitude:    record.Location.Latitude,
-		},
-	), nil
+	response := &GeoIPResponseData{
+		Special:     specialPurposeAnnotation(g.specialPurpose, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())),
+		Address:     ip.String(),
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.IsoCode,
+		City:        record.City.Names["en"],
+		Postcode:    record.Postal.Code,
+		Timezone:    record.Location.TimeZone,
+		Longitude:   record.Location.Longitude,
+		Latitude:    record.Location.Latitude,
+	}
+
+	if g.cloud != nil {
+		response.Cloud = g.cloud.annotate(netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return response, nil
 }
 
 type GeoIPResponseData struct {
@@ -100,15 +178,47 @@ type GeoIPResponseData struct {
 	Timezone    string  `json:"timezone"`
 	Longitude   float64 `json:"longitude"`
 	Latitude    float64 `json:"latitude"`
+	Special     string  `json:"special,omitempty"`
+	Cloud       string  `json:"cloud,omitempty"`
+}
+
+func (r *GeoIPResponseData) Header() []string {
+	return []string{"address", "country", "country_code", "city", "postcode", "timezone", "longitude", "latitude"}
+}
+
+func (r *GeoIPResponseData) Rows() [][]string {
+	return [][]string{
+		{r.Address, r.Country, r.CountryCode, r.City, r.Postcode, r.Timezone, fmt.Sprintf("%f", r.Longitude), fmt.Sprintf("%f", r.Latitude)},
+	}
 }
 
 func (r *GeoIPResponseData) String() string {
-	return fmt.Sprintf(`Address:        %s
-Country:        %s
-Country Code:   %s
-City:           %s
-Postcode        %s
-Timezone        %s
-Longitude       %f
-Latitude        %f`, r.Address, r.Country, r.CountryCode, r.City, r.Postcode, r.Timezone, r.Longitude, r.Latitude)
+	return r.render(false)
+}
+
+func (r *GeoIPResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *GeoIPResponseData) render(colour bool) string {
+	pairs := []keyValue{
+		{"Address:", r.Address},
+		{"Country:", r.Country},
+		{"Country Code:", r.CountryCode},
+		{"City:", r.City},
+		{"Postcode", r.Postcode},
+		{"Timezone", r.Timezone},
+		{"Longitude", fmt.Sprintf("%f", r.Longitude)},
+		{"Latitude", fmt.Sprintf("%f", r.Latitude)},
+	}
+
+	if r.Special != "" {
+		pairs = append(pairs, keyValue{"Special:", r.Special})
+	}
+
+	if r.Cloud != "" {
+		pairs = append(pairs, keyValue{"Cloud:", r.Cloud})
+	}
+
+	return renderKeyValues(16, colour, pairs...)
 }
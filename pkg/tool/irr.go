@@ -0,0 +1,456 @@
+package tool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	IRR_STATUS_MATCHED        = "matched"
+	IRR_STATUS_NOT_REGISTERED = "not-registered"
+	IRR_STATUS_NOT_ANNOUNCED  = "not-announced"
+)
+
+// rpslObject is a single RPSL object, with attribute names lower cased and
+// continuation lines folded into their attribute
+type rpslObject struct {
+	Class      string
+	Attributes map[string][]string
+}
+
+func (o *rpslObject) first(attribute string) string {
+	if values := o.Attributes[attribute]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// parseRPSL calls fn for each object in an RPSL dump. Objects are separated
+// by blank lines, with comments starting # or %
+func parseRPSL(r io.Reader, fn func(object *rpslObject)) error {
+	var object *rpslObject
+	var last string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.TrimSpace(line) == "" {
+			if object != nil {
+				fn(object)
+			}
+			object = nil
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		// Continuation lines start with whitespace or +
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '+' {
+			if object != nil && last != "" {
+				values := object.Attributes[last]
+				values[len(values)-1] = strings.TrimSpace(values[len(values)-1] + " " + strings.TrimSpace(line[1:]))
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		if comment := strings.Index(value, "#"); comment >= 0 {
+			value = value[:comment]
+		}
+		value = strings.TrimSpace(value)
+
+		if object == nil {
+			object = &rpslObject{Class: key, Attributes: make(map[string][]string)}
+		}
+		object.Attributes[key] = append(object.Attributes[key], value)
+		last = key
+	}
+
+	if object != nil {
+		fn(object)
+	}
+
+	return scanner.Err()
+}
+
+type irrRoute struct {
+	Prefix netip.Prefix
+	Origin uint32
+	Source string
+}
+
+type irrIndex struct {
+	routes   *prefixtrie.Trie[irrRoute]
+	origins  map[uint32][]irrRoute
+	asNames  map[uint32]string
+	count    int
+	loadedAt time.Time
+}
+
+func newIRRIndex() *irrIndex {
+	return &irrIndex{
+		routes:   prefixtrie.New[irrRoute](),
+		origins:  make(map[uint32][]irrRoute),
+		asNames:  make(map[uint32]string),
+		loadedAt: time.Now().UTC(),
+	}
+}
+
+func (i *irrIndex) add(object *rpslObject) {
+	switch object.Class {
+	case "route", "route6":
+		prefix, err := netip.ParsePrefix(object.first(object.Class))
+		if err != nil {
+			return
+		}
+
+		origin, err := parseASN(object.first("origin"))
+		if err != nil {
+			return
+		}
+
+		route := irrRoute{Prefix: prefix.Masked(), Origin: origin, Source: strings.ToUpper(object.first("source"))}
+		i.routes.Insert(route.Prefix, route)
+		i.origins[origin] = append(i.origins[origin], route)
+		i.count++
+	case "aut-num":
+		asn, err := parseASN(object.first("aut-num"))
+		if err != nil {
+			return
+		}
+
+		if _, ok := i.asNames[asn]; !ok {
+			i.asNames[asn] = object.first("as-name")
+		}
+	}
+}
+
+type IRR struct {
+	bgp        *BGP
+	sources    []string
+	index      atomic.Pointer[irrIndex]
+	indexMutex sync.Mutex
+}
+
+func NewIRR(bgp *BGP, sources []string) *IRR {
+	return &IRR{
+		bgp:     bgp,
+		sources: sources,
+	}
+}
+
+func (t *IRR) Paths() []string {
+	return []string{
+		"/bgp/irr/{query}",
+		"/bgp/irr/{query}/{length:[0-9]+}",
+	}
+}
+
+func (t *IRR) Method() string {
+	return "GET"
+}
+
+func (t *IRR) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query := vars["query"]
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	bgpIndex, err := t.bgp.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	irrIndex, err := t.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &IRRResponseData{
+		Query:    query,
+		LoadedAt: irrIndex.loadedAt,
+	}
+
+	var announced []BGPRoute
+	var registered []irrRoute
+	if asn, err := parseASN(query); err == nil {
+		response.Query = fmt.Sprintf("AS%d", asn)
+		response.ASName = irrIndex.asNames[asn]
+
+		for _, r := range bgpIndex.asns[asn] {
+			announced = append(announced, bgpIndex.routes[r])
+		}
+		registered = irrIndex.origins[asn]
+	} else {
+		prefix, err := parsePrefixOrAddr(query)
+		if err != nil {
+			return nil, err
+		}
+		response.Query = prefix.String()
+
+		// Compare the prefix and all more specifics
+		for _, match := range bgpIndex.trie.CoveredBy(prefix) {
+			for _, r := range match.Values {
+				announced = append(announced, bgpIndex.routes[r])
+			}
+		}
+		for _, match := range irrIndex.routes.CoveredBy(prefix) {
+			registered = append(registered, match.Values...)
+		}
+	}
+
+	response.Routes = compareIRRRoutes(announced, registered)
+
+	return NewToolResponse(response), nil
+}
+
+// compareIRRRoutes matches announced routes against registered route objects
+// by prefix and origin ASN
+func compareIRRRoutes(announced []BGPRoute, registered []irrRoute) []IRRResponseDataItem {
+	type routeKey struct {
+		prefix netip.Prefix
+		origin uint32
+	}
+
+	sources := make(map[routeKey][]string)
+	for _, route := range registered {
+		key := routeKey{prefix: route.Prefix, origin: route.Origin}
+		if !slices.Contains(sources[key], route.Source) {
+			sources[key] = append(sources[key], route.Source)
+		}
+	}
+
+	seen := make(map[routeKey]bool)
+	var items []IRRResponseDataItem
+	var keys []routeKey
+	for _, route := range announced {
+		prefix, err := netip.ParsePrefix(route.Route)
+		if err != nil {
+			continue
+		}
+
+		key := routeKey{prefix: prefix.Masked(), origin: route.ASNNumber}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		status := IRR_STATUS_NOT_REGISTERED
+		if _, ok := sources[key]; ok {
+			status = IRR_STATUS_MATCHED
+		}
+
+		keys = append(keys, key)
+		items = append(items, IRRResponseDataItem{
+			Prefix:  key.prefix.String(),
+			Origin:  key.origin,
+			Status:  status,
+			Sources: sources[key],
+		})
+	}
+
+	for key, keySources := range sources {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		keys = append(keys, key)
+		items = append(items, IRRResponseDataItem{
+			Prefix:  key.prefix.String(),
+			Origin:  key.origin,
+			Status:  IRR_STATUS_NOT_ANNOUNCED,
+			Sources: keySources,
+		})
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := keys[order[i]], keys[order[j]]
+		if a.prefix.Addr().Is4() != b.prefix.Addr().Is4() {
+			return a.prefix.Addr().Is4()
+		}
+		if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		if a.prefix.Bits() != b.prefix.Bits() {
+			return a.prefix.Bits() < b.prefix.Bits()
+		}
+		return a.origin < b.origin
+	})
+
+	sorted := []IRRResponseDataItem{}
+	for _, i := range order {
+		sorted = append(sorted, items[i])
+	}
+
+	return sorted
+}
+
+// Start loads the route objects, which takes minutes so can't be done on the
+// request path
+func (t *IRR) Start() {
+	go t.cronWork()
+}
+
+func (t *IRR) Cron() CronSpec {
+	return CronSpec{Cron: "0 4 * * *", Func: t.cronWork}
+}
+
+func (t *IRR) cronWork() {
+	if !t.indexMutex.TryLock() {
+		log.Info().Msg("IRR: Load already in progress")
+		return
+	}
+
+	log.Info().Msg("IRR: Starting cron")
+
+	err := t.loadIndex()
+	t.indexMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("IRR: Load failed, keeping current route objects")
+		return
+	}
+
+	log.Info().Msg("IRR: Cron completed")
+}
+
+func (t *IRR) getIndex() (*irrIndex, error) {
+	index := t.index.Load()
+	if index == nil {
+		return nil, errors.New("IRR data not yet loaded")
+	}
+
+	return index, nil
+}
+
+// loadIndex loads route objects from all sources, replacing the current index
+// only if every source loads successfully
+func (t *IRR) loadIndex() error {
+	if len(t.sources) == 0 {
+		return errors.New("no IRR sources configured")
+	}
+
+	index := newIRRIndex()
+	for _, source := range t.sources {
+		err := t.loadSource(index, source)
+		if err != nil {
+			return fmt.Errorf("failed to load IRR objects from %s: %w", source, err)
+		}
+	}
+
+	if index.count == 0 {
+		return errors.New("no route objects found in sources")
+	}
+
+	t.index.Store(index)
+
+	log.Debug().Int("routes", index.count).Int("autnums", len(index.asNames)).Msg("Loaded IRR route objects")
+	return nil
+}
+
+func (t *IRR) loadSource(index *irrIndex, source string) error {
+	body, err := openSource(source)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	return parseRPSL(body, index.add)
+}
+
+type IRRResponseDataItem struct {
+	Prefix  string   `json:"prefix"`
+	Origin  uint32   `json:"origin"`
+	Status  string   `json:"status"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+type IRRResponseData struct {
+	Query    string                `json:"query"`
+	ASName   string                `json:"as_name,omitempty"`
+	Routes   []IRRResponseDataItem `json:"routes"`
+	LoadedAt time.Time             `json:"loaded_at"`
+}
+
+func (r *IRRResponseData) count(status string) int {
+	count := 0
+	for _, route := range r.Routes {
+		if route.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r *IRRResponseData) Header() []string {
+	return []string{"prefix", "origin", "status", "sources"}
+}
+
+func (r *IRRResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, route := range r.Routes {
+		rows = append(rows, []string{route.Prefix, fmt.Sprintf("AS%d", route.Origin), route.Status, strings.Join(route.Sources, ",")})
+	}
+
+	return rows
+}
+
+func (r *IRRResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *IRRResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *IRRResponseData) render(colour bool) string {
+	pairs := []keyValue{{Key: "Query", Value: r.Query}}
+	if r.ASName != "" {
+		pairs = append(pairs, keyValue{Key: "AS Name", Value: r.ASName})
+	}
+	pairs = append(pairs,
+		keyValue{Key: "Matched", Value: colourise(fmt.Sprint(r.count(IRR_STATUS_MATCHED)), ANSI_GREEN, colour)},
+		keyValue{Key: "Not Registered", Value: colourise(fmt.Sprint(r.count(IRR_STATUS_NOT_REGISTERED)), ANSI_RED, colour)},
+		keyValue{Key: "Not Announced", Value: colourise(fmt.Sprint(r.count(IRR_STATUS_NOT_ANNOUNCED)), ANSI_YELLOW, colour)},
+		keyValue{Key: "Loaded", Value: r.LoadedAt.Format(time.RFC3339)},
+	)
+
+	output := renderKeyValues(16, colour, pairs...)
+	if len(r.Routes) == 0 {
+		return output
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
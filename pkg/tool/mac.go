@@ -2,21 +2,97 @@ package tool
 
 import (
 	"bufio"
-	"bytes"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/0x4c6565/lee.io/pkg/connection"
 	ierr "github.com/0x4c6565/lee.io/pkg/error"
 	"github.com/gorilla/mux"
-	"github.com/olekukonko/tablewriter"
 	"github.com/rs/zerolog/log"
 )
 
-const MAC_OUI_URL = "https://standards-oui.ieee.org/oui/oui.txt"
+const (
+	MAC_REGISTRY_MA_L = "MA-L"
+	MAC_REGISTRY_MA_M = "MA-M"
+	MAC_REGISTRY_MA_S = "MA-S"
+	MAC_REGISTRY_CID  = "CID"
+)
+
+// macRegistry is an IEEE assignment registry, with Bits being the length of
+// each assigned prefix
+type macRegistry struct {
+	Name string
+	URL  string
+	Bits int
+}
+
+var macRegistries = []macRegistry{
+	{Name: MAC_REGISTRY_MA_L, URL: "https://standards-oui.ieee.org/oui/oui.txt", Bits: 24},
+	{Name: MAC_REGISTRY_MA_M, URL: "https://standards-oui.ieee.org/oui28/mam.txt", Bits: 28},
+	{Name: MAC_REGISTRY_MA_S, URL: "https://standards-oui.ieee.org/oui36/oui36.txt", Bits: 36},
+	{Name: MAC_REGISTRY_CID, URL: "https://standards-oui.ieee.org/cid/cid.txt", Bits: 24},
+}
+
+// macMigrations bring installs predating the current schema.sql up to date, so
+// lookups and searches work before the first refresh, and are safe to run on
+// every start
+var macMigrations = []string{
+	"ALTER TABLE mac_oui " +
+		"MODIFY COLUMN `oui` varchar(12) NOT NULL," +
+		"ADD COLUMN IF NOT EXISTS `registry` varchar(8) NOT NULL DEFAULT 'MA-L'," +
+		"ADD COLUMN IF NOT EXISTS `block_size` int NOT NULL DEFAULT 24," +
+		"ADD UNIQUE KEY IF NOT EXISTS `mac_oui_oui_idx` (`oui`)," +
+		"ADD FULLTEXT KEY IF NOT EXISTS `mac_oui_company_name_idx` (`company_name`)",
+	"CREATE TABLE IF NOT EXISTS `mac_oui_refresh` (" +
+		"`id` int NOT NULL AUTO_INCREMENT," +
+		"`started_at` datetime NOT NULL," +
+		"`completed_at` datetime NOT NULL," +
+		"`status` varchar(16) NOT NULL," +
+		"`error` text NOT NULL," +
+		"`total` int NOT NULL," +
+		"`added` int NOT NULL," +
+		"`removed` int NOT NULL," +
+		"`renamed` int NOT NULL," +
+		"PRIMARY KEY (`id`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+	"CREATE TABLE IF NOT EXISTS `mac_oui_change` (" +
+		"`id` int NOT NULL AUTO_INCREMENT," +
+		"`refresh_id` int NOT NULL," +
+		"`change_type` varchar(16) NOT NULL," +
+		"`oui` varchar(12) NOT NULL," +
+		"`registry` varchar(8) NOT NULL," +
+		"`block_size` int NOT NULL," +
+		"`company_name` text NOT NULL," +
+		"`previous_company_name` text NOT NULL," +
+		"PRIMARY KEY (`id`)," +
+		"KEY `mac_oui_change_refresh_idx` (`refresh_id`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+}
+
+var macHexRegexp = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
+var macRegistryFieldRegexp = regexp.MustCompile(`\s\s+`)
+
+// MAC_FULLTEXT_MIN_TOKEN_SIZE and macFulltextStopwords mirror the InnoDB
+// FULLTEXT defaults. Such words are never indexed, so requiring them would
+// match nothing
+const MAC_FULLTEXT_MIN_TOKEN_SIZE = 3
+
+var macFulltextStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"com": true, "de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "who": true,
+	"will": true, "with": true, "und": true, "www": true,
+}
 
 type MACOUINotFoundError struct {
 	msg string
@@ -36,45 +112,157 @@ type MACOUI struct {
 	ID          int    `db:"id"`
 	OUI         string `db:"oui"`
 	CompanyName string `db:"company_name"`
+	Registry    string `db:"registry"`
+	BlockSize   int    `db:"block_size"`
+}
+
+type MACOUICompany struct {
+	CompanyName string  `db:"company_name"`
+	OUICount    int     `db:"oui_count"`
+	Score       float64 `db:"score"`
 }
 
 type MACOUIRepository struct {
-	conn connection.Connection
+	conn connection.Querier
 }
 
-func NewMACOUIRepository(conn connection.Connection) *MACOUIRepository {
+func NewMACOUIRepository(conn connection.Querier) *MACOUIRepository {
 	return &MACOUIRepository{
 		conn: conn,
 	}
 }
 
-func (s *MACOUIRepository) Get(oui string, companyName string) (*[]MACOUI, error) {
-	p := []MACOUI{}
-	err := s.conn.Select(&p, "SELECT * FROM mac_oui WHERE oui LIKE ? OR company_name LIKE ?", oui, companyName)
+func (s *MACOUIRepository) Migrate() error {
+	for _, migration := range macMigrations {
+		if _, err := s.conn.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByMAC returns the most specific assignments covering the given MAC
+// address or prefix, as upper cased hex digits without separators
+func (s *MACOUIRepository) GetByMAC(mac string) ([]MACOUI, error) {
+	results, err := s.GetByMACs([]string{mac})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, NewMACOUINotFoundError("MAC not found")
+		return nil, err
+	}
+
+	if results[mac] == nil {
+		return []MACOUI{}, nil
+	}
+
+	return results[mac], nil
+}
+
+// GetByMACs returns the most specific assignments covering each of the given
+// MAC addresses or prefixes with a single query, keyed by MAC
+func (s *MACOUIRepository) GetByMACs(macs []string) (map[string][]MACOUI, error) {
+	var candidates []interface{}
+	seen := make(map[string]bool)
+	for _, mac := range macs {
+		for _, length := range []int{6, 7, 9} {
+			if len(mac) >= length && !seen[mac[:length]] {
+				seen[mac[:length]] = true
+				candidates = append(candidates, mac[:length])
+			}
+		}
+	}
+
+	results := make(map[string][]MACOUI)
+	if len(candidates) == 0 {
+		return results, nil
+	}
+
+	p := []MACOUI{}
+	err := s.conn.Select(&p, "SELECT * FROM mac_oui WHERE oui IN (?"+strings.Repeat(",?", len(candidates)-1)+") ORDER BY block_size DESC", candidates...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	for _, mac := range macs {
+		for _, oui := range p {
+			if !strings.HasPrefix(mac, oui.OUI) {
+				continue
+			}
+			if len(results[mac]) > 0 && results[mac][0].BlockSize != oui.BlockSize {
+				break
+			}
+			results[mac] = append(results[mac], oui)
 		}
+	}
+
+	return results, nil
+}
+
+// GetByOUIPrefix returns a page of assignments starting with the given upper
+// cased hex digits
+func (s *MACOUIRepository) GetByOUIPrefix(prefix string, limit int, offset int) ([]MACOUI, error) {
+	p := []MACOUI{}
+	err := s.conn.Select(&p, "SELECT * FROM mac_oui WHERE oui LIKE ? ORDER BY oui LIMIT ? OFFSET ?", prefix+"%", limit, offset)
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 
-	return &p, nil
+	return p, nil
 }
 
-func (s *MACOUIRepository) Set(oui string, companyName string) error {
-	p := MACOUI{}
-	err := s.conn.Get(&p, "SELECT * FROM mac_oui WHERE oui = ?", oui)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			_, err = s.conn.Exec("INSERT INTO mac_oui (`oui`,`company_name`) VALUES (?,?)", oui, companyName)
-			return err
+// SearchCompanies returns a page of companies matching every word of the
+// query, using the company name FULLTEXT index where possible, with one row
+// per company ranked by relevance and then OUI count, along with the total
+// number of matching companies. In exact mode only companies named exactly
+// as the query are returned
+func (s *MACOUIRepository) SearchCompanies(query string, exact bool, limit int, offset int) ([]MACOUICompany, int, error) {
+	var terms, dropped []string
+	for _, token := range searchTokens(query) {
+		if len(token) >= MAC_FULLTEXT_MIN_TOKEN_SIZE && !macFulltextStopwords[token] {
+			terms = append(terms, "+"+token+"*")
+		} else {
+			dropped = append(dropped, token)
 		}
+	}
+
+	// Queries made up only of short words or stopwords, such as "HP" or
+	// "LG", fall back to a substring match, and words the index can't match
+	// are otherwise required as substrings, so "TP-Link" needs "tp"
+	where := "company_name LIKE ?"
+	args := []interface{}{"%" + query + "%"}
+	score := "0"
+	var scoreArgs []interface{}
+	if len(terms) > 0 {
+		match := strings.Join(terms, " ")
+		where = "MATCH(company_name) AGAINST (? IN BOOLEAN MODE)"
+		args = []interface{}{match}
+		score = "MAX(MATCH(company_name) AGAINST (? IN BOOLEAN MODE))"
+		scoreArgs = []interface{}{match}
+
+		for _, token := range dropped {
+			where += " AND company_name LIKE ?"
+			args = append(args, "%"+token+"%")
+		}
+	}
+
+	if exact {
+		where += " AND company_name = ?"
+		args = append(args, query)
+	}
 
-		return err
+	var total int
+	err := s.conn.Get(&total, "SELECT COUNT(DISTINCT company_name) FROM mac_oui WHERE "+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p := []MACOUICompany{}
+	err = s.conn.Select(&p, "SELECT company_name, COUNT(*) AS oui_count, "+score+" AS score FROM mac_oui WHERE "+where+" GROUP BY company_name ORDER BY score DESC, oui_count DESC, company_name LIMIT ? OFFSET ?",
+		append(append(scoreArgs, args...), limit, offset)...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
 	}
 
-	_, err = s.conn.Exec("UPDATE mac_oui SET company_name = ? WHERE oui = ?", companyName, oui)
-	return err
+	return p, total, nil
 }
 
 type MAC struct {
@@ -87,6 +275,20 @@ func NewMAC(connFactory connection.ConnectionFactory) *MAC {
 	}
 }
 
+// Start migrates the schema before any lookup or refresh uses it
+func (m *MAC) Start() {
+	conn, err := m.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("MAC: Failed to initialise database, skipping migration")
+		return
+	}
+
+	err = NewMACOUIRepository(conn).Migrate()
+	if err != nil {
+		log.Error().Err(err).Msg("MAC: Migration failed")
+	}
+}
+
 func (m *MAC) Paths() []string {
 	return []string{
 		"/mac",
@@ -99,6 +301,18 @@ func (m *MAC) Method() string {
 }
 
 func (m *MAC) Handle(r *http.Request) (*ToolResponse, error) {
+	conn, err := m.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialise database")
+		return nil, ierr.InternalServerError
+	}
+
+	macOUIRepository := NewMACOUIRepository(conn)
+
+	if r.Method == http.MethodPost {
+		return m.extract(r, macOUIRepository)
+	}
+
 	vars := mux.Vars(r)
 
 	query, ok := vars["query"]
@@ -106,51 +320,125 @@ func (m *MAC) Handle(r *http.Request) (*ToolResponse, error) {
 		return nil, errors.New("missing query")
 	}
 
-	mac := m.sanitiseMAC(query) + "%"
-	company := "%" + query + "%"
-
-	conn, err := m.connFactory.New()
+	opts, err := newSearchOptions(r)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to initialise database")
-		return nil, ierr.InternalServerError
+		return nil, err
 	}
 
-	macOUIRepository := NewMACOUIRepository(conn)
+	// Queries of at least an OUI's worth of hex digits are looked up by OUI,
+	// anything else is a company search. Full addresses are also analysed
+	if digits := m.stripMACSeparators(query); len(digits) >= 6 && macHexRegexp.MatchString(digits) {
+		lookup := m.sanitiseMAC(query)
 
-	results, err := macOUIRepository.Get(mac, company)
-	if err != nil {
-		var notFoundErr *MACOUINotFoundError
-		if errors.As(err, &notFoundErr) {
-			return nil, err
+		var mac net.HardwareAddr
+		if len(lookup) == 12 {
+			mac, _ = hex.DecodeString(lookup)
+			lookup = macLookupKey(lookup)
+		}
+
+		results, err := macOUIRepository.GetByMAC(lookup)
+		if err != nil {
+			log.Error().Err(err).Send()
+			return nil, errors.New("failed to retrieve MAC address")
+		}
+
+		output := MACResponseData{}
+		for _, result := range results {
+			output = append(output, MACResponseDataItem{
+				OUI:         result.OUI,
+				CompanyName: result.CompanyName,
+				Registry:    result.Registry,
+				Block:       formatMACBlock(result.OUI, result.BlockSize),
+			})
+		}
+
+		if mac != nil {
+			return NewToolResponse(analyseMAC(mac, output)), nil
+		}
+
+		if len(output) == 0 {
+			return nil, NewMACOUINotFoundError("MAC not found")
+		}
+
+		return NewToolResponse(&output), nil
+	}
+
+	// Shorter hex queries are OUI prefixes, unless nothing matches as they may
+	// also be company names such as "ACE"
+	if digits := m.stripMACSeparators(query); digits != "" && macHexRegexp.MatchString(digits) {
+		results, err := macOUIRepository.GetByOUIPrefix(strings.ToUpper(digits), opts.Limit, opts.offset())
+		if err != nil {
+			log.Error().Err(err).Send()
+			return nil, errors.New("failed to retrieve MAC address")
+		}
+
+		if len(results) > 0 {
+			output := MACResponseData{}
+			for _, result := range results {
+				output = append(output, MACResponseDataItem{
+					OUI:         result.OUI,
+					CompanyName: result.CompanyName,
+					Registry:    result.Registry,
+					Block:       formatMACBlock(result.OUI, result.BlockSize),
+				})
+			}
+
+			return NewToolResponse(&output), nil
 		}
+	}
 
+	companies, total, err := macOUIRepository.SearchCompanies(query, opts.Exact, opts.Limit, opts.offset())
+	if err != nil {
 		log.Error().Err(err).Send()
-		return nil, errors.New("failed to retrieve MAC address")
+		return nil, errors.New("failed to search MAC vendors")
 	}
 
-	var output MACResponseData
-	for _, result := range *results {
-		output = append(output, MACResponseDataItem{
-			OUI:         result.OUI,
-			CompanyName: result.CompanyName,
+	output := &MACCompanyResponseData{
+		SearchPage: newSearchPage(query, opts, total),
+		Results:    []MACCompanyResponseDataItem{},
+	}
+	for _, company := range companies {
+		output.Results = append(output.Results, MACCompanyResponseDataItem{
+			CompanyName: company.CompanyName,
+			OUIs:        company.OUICount,
+			Score:       company.Score,
 		})
 	}
 
-	return NewToolResponse(&output), nil
+	return NewToolResponse(output), nil
 }
 
-func (m *MAC) sanitiseMAC(mac string) string {
+func (m *MAC) stripMACSeparators(mac string) string {
 	mac = strings.Replace(mac, ":", "", -1)
 	mac = strings.Replace(mac, "-", "", -1)
 	mac = strings.Replace(mac, ".", "", -1)
 
-	if len(mac) > 6 {
-		mac = mac[:6]
+	return mac
+}
+
+func (m *MAC) sanitiseMAC(mac string) string {
+	mac = strings.ToUpper(m.stripMACSeparators(mac))
+
+	if len(mac) > 12 {
+		mac = mac[:12]
 	}
 
 	return mac
 }
 
+// formatMACBlock formats an assigned prefix as the first address of the
+// block with its length, e.g. 70:B3:D5:0A:50:00/36
+func formatMACBlock(oui string, blockSize int) string {
+	padded := oui + strings.Repeat("0", max(12-len(oui), 0))
+
+	var octets []string
+	for i := 0; i+2 <= len(padded); i += 2 {
+		octets = append(octets, padded[i:i+2])
+	}
+
+	return fmt.Sprintf("%s/%d", strings.Join(octets, ":"), blockSize)
+}
+
 func (m *MAC) Cron() CronSpec {
 	return CronSpec{Cron: "0 2 * * *", Func: m.cronWork}
 }
@@ -164,35 +452,59 @@ func (m *MAC) cronWork() {
 		return
 	}
 
-	macOUIRepository := NewMACOUIRepository(conn)
+	refresh := &MACOUIRefresh{StartedAt: time.Now().UTC(), Status: MAC_REFRESH_STATUS_SUCCESS}
+	changes, err := m.refresh(NewMACOUIRepository(conn), refresh)
+	refresh.CompletedAt = time.Now().UTC()
+	if err != nil {
+		log.Error().Err(err).Msg("MAC: Refresh failed, keeping current OUIs")
+		refresh.Status = MAC_REFRESH_STATUS_FAILED
+		refresh.Error = err.Error()
+	}
 
-	response, err := http.Get(MAC_OUI_URL)
+	err = m.recordRefresh(conn, refresh, changes)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to query MAC OUI URL")
-		return
+		log.Error().Err(err).Msg("Failed to record MAC OUI refresh")
 	}
 
-	defer response.Body.Close()
+	log.Info().Int("added", refresh.Added).Int("removed", refresh.Removed).Int("renamed", refresh.Renamed).Msg("MAC: Cron completed")
+}
+
+// parseMACRegistry parses an IEEE registry text file, calling fn with each
+// assigned prefix truncated to bits. MA-M and MA-S entries are listed as the
+// parent MA-L's (hex) line followed by a (base 16) range within it, e.g.
+// 70-B3-D5 (hex) then 0A5000-0A5FFF (base 16) for 70B3D50A5
+func parseMACRegistry(r io.Reader, bits int, fn func(oui string, companyName string)) error {
+	var parent string
 
-	scanner := bufio.NewScanner(response.Body)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), "(base 16)") {
-			re := regexp.MustCompile(`\s\s+`)
-			fields := re.Split(scanner.Text(), 3)
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.Contains(line, "(hex)"):
+			fields := strings.Fields(line)
+			parent = strings.ToUpper(strings.ReplaceAll(fields[0], "-", ""))
+		case strings.Contains(line, "(base 16)"):
+			fields := macRegistryFieldRegexp.Split(line, 3)
 			if len(fields) != 3 {
-				log.Error().Msgf("Line not in expected format: %s :: %d", scanner.Text(), len(fields))
+				log.Error().Msgf("Line not in expected format: %s :: %d", line, len(fields))
 				continue
 			}
 
-			err := macOUIRepository.Set(fields[0], fields[2])
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed to set OUI in DB")
+			oui := strings.ToUpper(fields[0])
+			if start, _, ok := strings.Cut(oui, "-"); ok {
+				oui = parent + start
+			}
+			if len(oui) < bits/4 || !macHexRegexp.MatchString(oui) {
+				log.Error().Msgf("Line not in expected format: %s", line)
 				continue
 			}
+
+			fn(oui[:bits/4], strings.TrimSpace(fields[2]))
 		}
 	}
 
-	log.Info().Msg("MAC: Cron completed")
+	return scanner.Err()
 }
 
 type MACResponseData []MACResponseDataItem
@@ -200,17 +512,59 @@ type MACResponseData []MACResponseDataItem
 type MACResponseDataItem struct {
 	OUI         string `json:"oui"`
 	CompanyName string `json:"company_name"`
+	Registry    string `json:"registry"`
+	Block       string `json:"block"`
 }
 
-func (r *MACResponseData) String() string {
-	output := new(bytes.Buffer)
-	table := tablewriter.NewWriter(output)
-	table.Header([]string{"oui", "company"})
+func (r *MACResponseData) Header() []string {
+	return []string{"oui", "company", "registry", "block"}
+}
 
+func (r *MACResponseData) Rows() [][]string {
+	var rows [][]string
 	for _, mac := range *r {
-		table.Append([]string{mac.OUI, mac.CompanyName})
+		rows = append(rows, []string{mac.OUI, mac.CompanyName, mac.Registry, mac.Block})
 	}
-	table.Render()
 
-	return output.String()
+	return rows
+}
+
+func (r *MACResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *MACResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
+
+type MACCompanyResponseDataItem struct {
+	CompanyName string  `json:"company_name"`
+	OUIs        int     `json:"ouis"`
+	Score       float64 `json:"score"`
+}
+
+type MACCompanyResponseData struct {
+	SearchPage
+	Results []MACCompanyResponseDataItem `json:"results"`
+}
+
+func (r *MACCompanyResponseData) Header() []string {
+	return []string{"company", "ouis"}
+}
+
+func (r *MACCompanyResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, company := range r.Results {
+		rows = append(rows, []string{company.CompanyName, strconv.Itoa(company.OUIs)})
+	}
+
+	return rows
+}
+
+func (r *MACCompanyResponseData) String() string {
+	return renderTableData(r, false) + "\n" + r.footer()
+}
+
+func (r *MACCompanyResponseData) ColourString() string {
+	return renderTableData(r, true) + "\n" + r.footer()
 }
@@ -0,0 +1,176 @@
+package tool
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+const (
+	MAC_TYPE_UNICAST   = "unicast"
+	MAC_TYPE_MULTICAST = "multicast"
+	MAC_TYPE_BROADCAST = "broadcast"
+)
+
+const (
+	MAC_ADMINISTRATION_UNIVERSAL = "universal"
+	MAC_ADMINISTRATION_LOCAL     = "local"
+)
+
+// macWellKnownGroups are well-known multicast addresses
+var macWellKnownGroups = map[string]string{
+	"0180c2000000": "Spanning Tree (STP) / bridge group",
+	"0180c2000001": "Ethernet flow control (PAUSE)",
+	"0180c2000002": "Slow protocols (LACP, OAM)",
+	"0180c2000003": "802.1X PAE / LLDP nearest non-TPMR bridge",
+	"0180c200000e": "LLDP nearest bridge / PTP peer delay",
+	"0180c2000010": "All bridges",
+	"01000ccccccc": "Cisco CDP, VTP, DTP and PAgP",
+	"01000ccccccd": "Cisco PVST+",
+	"01000cdddddd": "Cisco CGMP",
+	"011b19000000": "Precision Time Protocol (PTP)",
+	"01005e000001": "IPv4 all hosts (224.0.0.1)",
+	"01005e000002": "IPv4 all routers (224.0.0.2)",
+	"01005e000005": "OSPF all routers (224.0.0.5)",
+	"01005e000006": "OSPF designated routers (224.0.0.6)",
+	"01005e000009": "RIPv2 routers (224.0.0.9)",
+	"01005e00000a": "EIGRP routers (224.0.0.10)",
+	"01005e000012": "VRRP (224.0.0.18)",
+	"01005e0000fb": "mDNS (224.0.0.251)",
+	"01005e0000fc": "LLMNR (224.0.0.252)",
+	"01005e7ffffa": "SSDP (239.255.255.250)",
+	"333300000001": "IPv6 all nodes (ff02::1)",
+	"333300000002": "IPv6 all routers (ff02::2)",
+	"3333000000fb": "IPv6 mDNS (ff02::fb)",
+	"333300010002": "DHCPv6 relay agents and servers (ff02::1:2)",
+}
+
+type MACNotations struct {
+	Colon     string `json:"colon"`
+	Hyphen    string `json:"hyphen"`
+	Cisco     string `json:"cisco"`
+	Bare      string `json:"bare"`
+	EUI64     string `json:"eui64"`
+	LinkLocal string `json:"link_local"`
+}
+
+type MACAnalysisResponseData struct {
+	Address        string                `json:"address"`
+	Type           string                `json:"type"`
+	Administration string                `json:"administration"`
+	Randomised     bool                  `json:"randomised"`
+	Group          string                `json:"group,omitempty"`
+	Notations      MACNotations          `json:"notations"`
+	Vendors        []MACResponseDataItem `json:"vendors"`
+}
+
+// analyseMAC describes an EUI-48 address. Vendors should be those registered
+// for the address's OUI, as a locally administered unicast address without a
+// registered CID is likely randomised
+func analyseMAC(mac net.HardwareAddr, vendors []MACResponseDataItem) *MACAnalysisResponseData {
+	bare := fmt.Sprintf("%x", []byte(mac))
+
+	response := &MACAnalysisResponseData{
+		Address:        mac.String(),
+		Type:           MAC_TYPE_UNICAST,
+		Administration: MAC_ADMINISTRATION_UNIVERSAL,
+		Notations: MACNotations{
+			Colon:  mac.String(),
+			Hyphen: strings.ToUpper(strings.ReplaceAll(mac.String(), ":", "-")),
+			Cisco:  bare[0:4] + "." + bare[4:8] + "." + bare[8:12],
+			Bare:   bare,
+			EUI64:  net.HardwareAddr{mac[0], mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}.String(),
+		},
+		Vendors: vendors,
+	}
+
+	if mac[0]&0x02 != 0 {
+		response.Administration = MAC_ADMINISTRATION_LOCAL
+	}
+
+	switch {
+	case bare == "ffffffffffff":
+		response.Type = MAC_TYPE_BROADCAST
+		response.Group = "Broadcast"
+	case mac[0]&0x01 != 0:
+		response.Type = MAC_TYPE_MULTICAST
+		response.Group = macMulticastGroup(mac, bare)
+	default:
+		response.Randomised = response.Administration == MAC_ADMINISTRATION_LOCAL && len(vendors) == 0
+		response.Notations.LinkLocal = generateEUI(net.ParseIP("fe80::"), mac).String()
+	}
+
+	return response
+}
+
+// macMulticastGroup names a well-known multicast address, or the IPv4/IPv6
+// groups mapped to it
+func macMulticastGroup(mac net.HardwareAddr, bare string) string {
+	if group, ok := macWellKnownGroups[bare]; ok {
+		return group
+	}
+
+	switch {
+	case strings.HasPrefix(bare, "01005e") && mac[3]&0x80 == 0:
+		group := netip.AddrFrom4([4]byte{224, mac[3], mac[4], mac[5]})
+		return fmt.Sprintf("IPv4 multicast (%s and 31 overlapping groups)", group)
+	case strings.HasPrefix(bare, "3333ff"):
+		return fmt.Sprintf("IPv6 solicited-node multicast (ff02::1:ff%s:%s)", bare[6:8], bare[8:12])
+	case strings.HasPrefix(bare, "3333"):
+		return fmt.Sprintf("IPv6 multicast (groups ending %s:%s)", bare[4:8], bare[8:12])
+	case strings.HasPrefix(bare, "0180c20000") && mac[5] < 0x10:
+		return "IEEE 802.1 reserved bridge group"
+	}
+
+	return ""
+}
+
+func (r *MACAnalysisResponseData) Header() []string {
+	return []string{"oui", "company", "registry", "block"}
+}
+
+func (r *MACAnalysisResponseData) Rows() [][]string {
+	vendors := MACResponseData(r.Vendors)
+	return vendors.Rows()
+}
+
+func (r *MACAnalysisResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *MACAnalysisResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *MACAnalysisResponseData) render(colour bool) string {
+	administration := r.Administration
+	if r.Randomised {
+		administration = administration + colourise(" (likely randomised)", ANSI_YELLOW, colour)
+	}
+
+	pairs := []keyValue{
+		{Key: "Address:", Value: r.Address},
+		{Key: "Type:", Value: r.Type},
+		{Key: "Administration:", Value: administration},
+	}
+	if r.Group != "" {
+		pairs = append(pairs, keyValue{Key: "Group:", Value: r.Group})
+	}
+	pairs = append(pairs,
+		keyValue{Key: "Hyphen:", Value: r.Notations.Hyphen},
+		keyValue{Key: "Cisco:", Value: r.Notations.Cisco},
+		keyValue{Key: "Bare:", Value: r.Notations.Bare},
+		keyValue{Key: "EUI-64:", Value: r.Notations.EUI64},
+	)
+	if r.Notations.LinkLocal != "" {
+		pairs = append(pairs, keyValue{Key: "Link-local:", Value: r.Notations.LinkLocal})
+	}
+
+	output := renderKeyValues(16, colour, pairs...)
+	if len(r.Vendors) == 0 {
+		return output + "\n\n" + colourise("No registered vendor", ANSI_YELLOW, colour)
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
@@ -0,0 +1,218 @@
+package tool
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	ierr "github.com/0x4c6565/lee.io/pkg/error"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+type MACChanges struct {
+	mac *MAC
+}
+
+func NewMACChanges(mac *MAC) *MACChanges {
+	return &MACChanges{mac: mac}
+}
+
+func (c *MACChanges) Paths() []string {
+	return []string{
+		"/mac/changes",
+		"/mac/changes/{refresh:(?:[0-9]+|latest)}",
+	}
+}
+
+func (c *MACChanges) Method() string {
+	return "GET"
+}
+
+func (c *MACChanges) Handle(r *http.Request) (*ToolResponse, error) {
+	conn, err := c.mac.connFactory.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialise database")
+		return nil, ierr.InternalServerError
+	}
+	macOUIRepository := NewMACOUIRepository(conn)
+
+	vars := mux.Vars(r)
+	refreshID, ok := vars["refresh"]
+	if !ok {
+		refreshes, err := macOUIRepository.GetRefreshes(MAC_REFRESH_HISTORY_LIMIT)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve MAC OUI refreshes")
+			return nil, errors.New("failed to query OUI refreshes")
+		}
+
+		response := MACRefreshResponseData{}
+		for _, refresh := range refreshes {
+			response = append(response, newMACRefreshResponseDataItem(refresh))
+		}
+
+		return NewToolResponse(&response), nil
+	}
+
+	id := 0
+	if refreshID != "latest" {
+		id, _ = strconv.Atoi(refreshID)
+	}
+
+	refresh, err := macOUIRepository.GetRefresh(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("refresh not found")
+		}
+
+		log.Error().Err(err).Msg("Failed to retrieve MAC OUI refresh")
+		return nil, errors.New("failed to query OUI refreshes")
+	}
+
+	changes, err := macOUIRepository.GetChanges(refresh.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve MAC OUI changes")
+		return nil, errors.New("failed to query OUI changes")
+	}
+
+	response := &MACChangesResponseData{
+		Refresh: newMACRefreshResponseDataItem(*refresh),
+		Changes: []MACChangesResponseDataItem{},
+	}
+	for _, change := range changes {
+		response.Changes = append(response.Changes, MACChangesResponseDataItem{
+			Change:              change.ChangeType,
+			OUI:                 change.OUI,
+			Registry:            change.Registry,
+			Block:               formatMACBlock(change.OUI, change.BlockSize),
+			CompanyName:         change.CompanyName,
+			PreviousCompanyName: change.PreviousCompanyName,
+		})
+	}
+
+	return NewToolResponse(response), nil
+}
+
+type MACRefreshResponseDataItem struct {
+	ID          int       `json:"id"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Total       int       `json:"total"`
+	Added       int       `json:"added"`
+	Removed     int       `json:"removed"`
+	Renamed     int       `json:"renamed"`
+}
+
+func newMACRefreshResponseDataItem(refresh MACOUIRefresh) MACRefreshResponseDataItem {
+	return MACRefreshResponseDataItem{
+		ID:          refresh.ID,
+		StartedAt:   refresh.StartedAt,
+		CompletedAt: refresh.CompletedAt,
+		Status:      refresh.Status,
+		Error:       refresh.Error,
+		Total:       refresh.Total,
+		Added:       refresh.Added,
+		Removed:     refresh.Removed,
+		Renamed:     refresh.Renamed,
+	}
+}
+
+type MACRefreshResponseData []MACRefreshResponseDataItem
+
+func (r *MACRefreshResponseData) Header() []string {
+	return []string{"id", "completed_at", "status", "total", "added", "removed", "renamed", "error"}
+}
+
+func (r *MACRefreshResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, refresh := range *r {
+		rows = append(rows, []string{
+			strconv.Itoa(refresh.ID),
+			refresh.CompletedAt.Format(time.RFC3339),
+			refresh.Status,
+			strconv.Itoa(refresh.Total),
+			strconv.Itoa(refresh.Added),
+			strconv.Itoa(refresh.Removed),
+			strconv.Itoa(refresh.Renamed),
+			refresh.Error,
+		})
+	}
+
+	return rows
+}
+
+func (r *MACRefreshResponseData) String() string {
+	return renderTableData(r, false)
+}
+
+func (r *MACRefreshResponseData) ColourString() string {
+	return renderTableData(r, true)
+}
+
+type MACChangesResponseDataItem struct {
+	Change              string `json:"change"`
+	OUI                 string `json:"oui"`
+	Registry            string `json:"registry"`
+	Block               string `json:"block"`
+	CompanyName         string `json:"company_name,omitempty"`
+	PreviousCompanyName string `json:"previous_company_name,omitempty"`
+}
+
+type MACChangesResponseData struct {
+	Refresh MACRefreshResponseDataItem   `json:"refresh"`
+	Changes []MACChangesResponseDataItem `json:"changes"`
+}
+
+func (r *MACChangesResponseData) Header() []string {
+	return []string{"change", "oui", "registry", "block", "company", "previous_company"}
+}
+
+func (r *MACChangesResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, change := range r.Changes {
+		rows = append(rows, []string{change.Change, change.OUI, change.Registry, change.Block, change.CompanyName, change.PreviousCompanyName})
+	}
+
+	return rows
+}
+
+func (r *MACChangesResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *MACChangesResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *MACChangesResponseData) render(colour bool) string {
+	status := colourise(r.Refresh.Status, ANSI_GREEN, colour)
+	if r.Refresh.Status != MAC_REFRESH_STATUS_SUCCESS {
+		status = colourise(r.Refresh.Status, ANSI_RED, colour)
+	}
+
+	pairs := []keyValue{
+		{Key: "Refresh:", Value: strconv.Itoa(r.Refresh.ID)},
+		{Key: "Completed:", Value: r.Refresh.CompletedAt.Format(time.RFC3339)},
+		{Key: "Status:", Value: status},
+	}
+	if r.Refresh.Error != "" {
+		pairs = append(pairs, keyValue{Key: "Error:", Value: r.Refresh.Error})
+	}
+	pairs = append(pairs,
+		keyValue{Key: "Total:", Value: strconv.Itoa(r.Refresh.Total)},
+		keyValue{Key: "Added:", Value: strconv.Itoa(r.Refresh.Added)},
+		keyValue{Key: "Removed:", Value: strconv.Itoa(r.Refresh.Removed)},
+		keyValue{Key: "Renamed:", Value: strconv.Itoa(r.Refresh.Renamed)},
+	)
+
+	output := renderKeyValues(12, colour, pairs...)
+	if len(r.Changes) == 0 {
+		return output
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
@@ -0,0 +1,296 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	MAC_OUTPUT_ANNOTATE = "annotate"
+	MAC_OUTPUT_TABLE    = "table"
+	MAC_OUTPUT_CSV      = "csv"
+)
+
+// macExtractRegexp matches MACs in colon (including the single digit octets
+// of BSD arp), hyphen, Cisco dotted and bare hex notation
+var macExtractRegexp = regexp.MustCompile(`(?i)[0-9a-f]{1,2}(?::[0-9a-f]{1,2}){5}|[0-9a-f]{2}(?:-[0-9a-f]{2}){5}|[0-9a-f]{4}\.[0-9a-f]{4}\.[0-9a-f]{4}|[0-9a-f]{12}`)
+
+type macExtractLine struct {
+	Line string
+	MACs []string
+}
+
+// extractMACs returns each line of the text along with the MACs found on it,
+// normalised to lower cased bare hex
+func extractMACs(r io.Reader) ([]macExtractLine, error) {
+	var lines []macExtractLine
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := macExtractLine{Line: scanner.Text()}
+		for _, loc := range macExtractRegexp.FindAllStringIndex(line.Line, -1) {
+			if mac, ok := normaliseExtractedMAC(line.Line, loc[0], loc[1]); ok {
+				line.MACs = append(line.MACs, mac)
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// normaliseExtractedMAC rejects matches which are part of a longer token, such
+// as the trailing groups of an IPv6 address or a longer hex string, and bare
+// hex matches without a hex letter, which are more likely decimal numbers such
+// as phone numbers or timestamps
+func normaliseExtractedMAC(s string, start int, end int) (string, bool) {
+	match := s[start:end]
+
+	separator := byte(0)
+	if i := strings.IndexAny(match, ":-."); i >= 0 {
+		separator = match[i]
+	}
+
+	adjacent := func(c byte) bool {
+		return c == separator || c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+	}
+	if (start > 0 && adjacent(s[start-1])) || (end < len(s) && adjacent(s[end])) {
+		return "", false
+	}
+
+	if separator == 0 && !strings.ContainsAny(match, "abcdefABCDEF") {
+		return "", false
+	}
+
+	var bare string
+	if separator == ':' {
+		for _, octet := range strings.Split(match, ":") {
+			bare += fmt.Sprintf("%02s", octet)
+		}
+	} else {
+		bare = strings.NewReplacer("-", "", ".", "").Replace(match)
+	}
+
+	return strings.ToLower(bare), true
+}
+
+// macLookupKey returns the upper cased hex used to look up a MAC's vendor.
+// Group addresses belong to the owner of the OUI with the individual/group bit
+// cleared
+func macLookupKey(bare string) string {
+	key := strings.ToUpper(bare)
+	if len(key) < 2 {
+		return key
+	}
+
+	b, err := strconv.ParseUint(key[:2], 16, 8)
+	if err != nil {
+		return key
+	}
+
+	return fmt.Sprintf("%02X", b&^0x01) + key[2:]
+}
+
+// extract resolves every MAC found in the request body with a single lookup
+func (m *MAC) extract(r *http.Request, macOUIRepository *MACOUIRepository) (*ToolResponse, error) {
+	output := r.URL.Query().Get("output")
+	switch output {
+	case "":
+		output = MAC_OUTPUT_ANNOTATE
+	case MAC_OUTPUT_ANNOTATE, MAC_OUTPUT_TABLE, MAC_OUTPUT_CSV:
+	default:
+		return nil, errors.New("invalid output - must be annotate, table or csv")
+	}
+
+	if r.Body == nil {
+		return nil, errors.New("missing text")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, BULK_MAX_BODY_SIZE))
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, errors.New("failed to read text")
+	}
+	r.Body.Close()
+
+	lines, err := extractMACs(bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, errors.New("failed to read text")
+	}
+
+	var unique []string
+	counts := make(map[string]int)
+	keys := make(map[string]bool)
+	var lookups []string
+	for _, line := range lines {
+		for _, mac := range line.MACs {
+			if counts[mac] == 0 {
+				unique = append(unique, mac)
+
+				if key := macLookupKey(mac); !keys[key] {
+					keys[key] = true
+					lookups = append(lookups, key)
+				}
+			}
+			counts[mac]++
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil, errors.New("no MAC addresses found")
+	}
+
+	if len(unique) > BULK_MAX_QUERIES {
+		return nil, fmt.Errorf("too many MAC addresses - maximum is %d", BULK_MAX_QUERIES)
+	}
+
+	results, err := macOUIRepository.GetByMACs(lookups)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return nil, errors.New("failed to retrieve MAC addresses")
+	}
+
+	vendor := func(mac string) MACExtractedResponseDataItem {
+		item := MACExtractedResponseDataItem{MAC: formatExtractedMAC(mac), Count: counts[mac]}
+		if matches := results[macLookupKey(mac)]; len(matches) > 0 {
+			item.OUI = matches[0].OUI
+			item.CompanyName = matches[0].CompanyName
+			item.Registry = matches[0].Registry
+			item.Block = formatMACBlock(matches[0].OUI, matches[0].BlockSize)
+		}
+
+		return item
+	}
+
+	if output == MAC_OUTPUT_ANNOTATE {
+		response := &MACAnnotatedResponseData{}
+		for _, line := range lines {
+			annotated := MACAnnotatedResponseDataLine{Line: line.Line}
+			for _, mac := range line.MACs {
+				item := vendor(mac)
+				annotated.MACs = append(annotated.MACs, MACAnnotatedResponseDataMAC{MAC: item.MAC, CompanyName: item.CompanyName})
+			}
+			response.Lines = append(response.Lines, annotated)
+		}
+
+		return NewToolResponse(response), nil
+	}
+
+	response := &MACExtractedResponseData{csv: output == MAC_OUTPUT_CSV}
+	for _, mac := range unique {
+		response.MACs = append(response.MACs, vendor(mac))
+	}
+
+	return NewToolResponse(response), nil
+}
+
+func formatExtractedMAC(bare string) string {
+	var octets []string
+	for i := 0; i+2 <= len(bare); i += 2 {
+		octets = append(octets, bare[i:i+2])
+	}
+
+	return strings.Join(octets, ":")
+}
+
+type MACAnnotatedResponseDataMAC struct {
+	MAC         string `json:"mac"`
+	CompanyName string `json:"company_name,omitempty"`
+}
+
+type MACAnnotatedResponseDataLine struct {
+	Line string                        `json:"line"`
+	MACs []MACAnnotatedResponseDataMAC `json:"macs,omitempty"`
+}
+
+type MACAnnotatedResponseData struct {
+	Lines []MACAnnotatedResponseDataLine `json:"lines"`
+}
+
+func (r *MACAnnotatedResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *MACAnnotatedResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *MACAnnotatedResponseData) render(colour bool) string {
+	var lines []string
+	for _, line := range r.Lines {
+		var annotations []string
+		for _, mac := range line.MACs {
+			if mac.CompanyName == "" {
+				annotations = append(annotations, colourise("[unknown]", ANSI_YELLOW, colour))
+				continue
+			}
+			annotations = append(annotations, colourise("["+mac.CompanyName+"]", ANSI_BOLD_CYAN, colour))
+		}
+
+		if len(annotations) == 0 {
+			lines = append(lines, line.Line)
+			continue
+		}
+		lines = append(lines, line.Line+"  "+strings.Join(annotations, " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type MACExtractedResponseDataItem struct {
+	MAC         string `json:"mac"`
+	OUI         string `json:"oui,omitempty"`
+	CompanyName string `json:"company_name,omitempty"`
+	Registry    string `json:"registry,omitempty"`
+	Block       string `json:"block,omitempty"`
+	Count       int    `json:"count"`
+}
+
+type MACExtractedResponseData struct {
+	MACs []MACExtractedResponseDataItem `json:"macs"`
+	csv  bool
+}
+
+func (r *MACExtractedResponseData) Header() []string {
+	return []string{"mac", "company", "registry", "block", "count"}
+}
+
+func (r *MACExtractedResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, mac := range r.MACs {
+		rows = append(rows, []string{mac.MAC, mac.CompanyName, mac.Registry, mac.Block, strconv.Itoa(mac.Count)})
+	}
+
+	return rows
+}
+
+func (r *MACExtractedResponseData) String() string {
+	if !r.csv {
+		return renderTableData(r, false)
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(r.Header())
+	w.WriteAll(r.Rows())
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (r *MACExtractedResponseData) ColourString() string {
+	if r.csv {
+		return r.String()
+	}
+
+	return renderTableData(r, true)
+}
@@ -0,0 +1,39 @@
+package tool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractMACs(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{name: "colon", line: "link/ether 00:1A:2b:3c:4d:5e brd ff:ff:ff:ff:ff:ff", expected: []string{"001a2b3c4d5e", "ffffffffffff"}},
+		{name: "BSD arp single digit octets", line: "? (192.0.2.1) at 0:1a:2b:3:4d:5e on en0", expected: []string{"001a2b034d5e"}},
+		{name: "hyphen", line: "Physical Address: 00-1A-2B-3C-4D-5E", expected: []string{"001a2b3c4d5e"}},
+		{name: "Cisco dotted", line: "1    001a.2b3c.4d5e    DYNAMIC     Gi0/1", expected: []string{"001a2b3c4d5e"}},
+		{name: "bare hex", line: "mac=001a2b3c4d5e", expected: []string{"001a2b3c4d5e"}},
+		{name: "bare decimal", line: "call 447700900123 at 202610190000", expected: nil},
+		{name: "longer hex string", line: "sha 001a2b3c4d5e6f", expected: nil},
+		{name: "IPv6 address", line: "inet6 2001:db8:0:1:2:3:4:5", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines, err := extractMACs(strings.NewReader(test.line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 {
+				t.Fatalf("expected 1 line, got %d", len(lines))
+			}
+			if !reflect.DeepEqual(lines[0].MACs, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, lines[0].MACs)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package tool
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x4c6565/lee.io/pkg/connection"
+	"github.com/rs/zerolog/log"
+)
+
+const MAC_INSERT_BATCH_SIZE = 1000
+const MAC_MIN_OUI_RATIO = 0.9
+const MAC_REFRESH_HISTORY_LIMIT = 30
+
+// MAC_OUI_STAGING_DDL matches mac_oui in schema.sql, so that installs predating
+// the current definition are migrated by their first refresh
+const MAC_OUI_STAGING_DDL = "CREATE TABLE `mac_oui_staging` (" +
+	"`id` int NOT NULL AUTO_INCREMENT," +
+	"`oui` varchar(12) NOT NULL," +
+	"`company_name` text NOT NULL," +
+	"`registry` varchar(8) NOT NULL DEFAULT 'MA-L'," +
+	"`block_size` int NOT NULL DEFAULT 24," +
+	"PRIMARY KEY (`id`)," +
+	"UNIQUE KEY `mac_oui_oui_idx` (`oui`)," +
+	"FULLTEXT KEY `mac_oui_company_name_idx` (`company_name`)" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+const (
+	MAC_REFRESH_STATUS_SUCCESS = "success"
+	MAC_REFRESH_STATUS_FAILED  = "failed"
+)
+
+const (
+	MAC_CHANGE_ADDED   = "added"
+	MAC_CHANGE_REMOVED = "removed"
+	MAC_CHANGE_RENAMED = "renamed"
+)
+
+type MACOUIRefresh struct {
+	ID          int       `db:"id"`
+	StartedAt   time.Time `db:"started_at"`
+	CompletedAt time.Time `db:"completed_at"`
+	Status      string    `db:"status"`
+	Error       string    `db:"error"`
+	Total       int       `db:"total"`
+	Added       int       `db:"added"`
+	Removed     int       `db:"removed"`
+	Renamed     int       `db:"renamed"`
+}
+
+type MACOUIChange struct {
+	ID                  int    `db:"id"`
+	RefreshID           int    `db:"refresh_id"`
+	ChangeType          string `db:"change_type"`
+	OUI                 string `db:"oui"`
+	Registry            string `db:"registry"`
+	BlockSize           int    `db:"block_size"`
+	CompanyName         string `db:"company_name"`
+	PreviousCompanyName string `db:"previous_company_name"`
+}
+
+func (s *MACOUIRepository) GetAll() ([]MACOUI, error) {
+	p := []MACOUI{}
+	err := s.conn.Select(&p, "SELECT * FROM mac_oui")
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Replace loads the OUIs into a staging table before swapping it with the
+// current table in a single atomic rename, so lookups never see a partially
+// loaded table
+func (s *MACOUIRepository) Replace(ouis []MACOUI) error {
+	for _, statement := range []string{
+		"DROP TABLE IF EXISTS mac_oui_staging",
+		"DROP TABLE IF EXISTS mac_oui_previous",
+		MAC_OUI_STAGING_DDL,
+	} {
+		if _, err := s.conn.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(ouis); start += MAC_INSERT_BATCH_SIZE {
+		end := min(start+MAC_INSERT_BATCH_SIZE, len(ouis))
+
+		var placeholders []string
+		var args []interface{}
+		for _, oui := range ouis[start:end] {
+			placeholders = append(placeholders, "(?,?,?,?)")
+			args = append(args, oui.OUI, oui.CompanyName, oui.Registry, oui.BlockSize)
+		}
+
+		_, err := s.conn.Exec("INSERT INTO mac_oui_staging (`oui`,`company_name`,`registry`,`block_size`) VALUES "+strings.Join(placeholders, ","), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.conn.Exec("RENAME TABLE mac_oui TO mac_oui_previous, mac_oui_staging TO mac_oui")
+	if err != nil {
+		return err
+	}
+
+	// The new OUIs are live once renamed, and any leftover table is dropped
+	// by the next refresh
+	_, err = s.conn.Exec("DROP TABLE mac_oui_previous")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to drop previous MAC OUI table")
+	}
+
+	return nil
+}
+
+func (s *MACOUIRepository) CreateRefresh(refresh *MACOUIRefresh) error {
+	result, err := s.conn.Exec("INSERT INTO mac_oui_refresh (`started_at`,`completed_at`,`status`,`error`,`total`,`added`,`removed`,`renamed`) VALUES (?,?,?,?,?,?,?,?)",
+		refresh.StartedAt, refresh.CompletedAt, refresh.Status, refresh.Error, refresh.Total, refresh.Added, refresh.Removed, refresh.Renamed)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	refresh.ID = int(id)
+
+	return nil
+}
+
+func (s *MACOUIRepository) InsertChanges(changes []MACOUIChange) error {
+	for start := 0; start < len(changes); start += MAC_INSERT_BATCH_SIZE {
+		end := min(start+MAC_INSERT_BATCH_SIZE, len(changes))
+
+		var placeholders []string
+		var args []interface{}
+		for _, change := range changes[start:end] {
+			placeholders = append(placeholders, "(?,?,?,?,?,?,?)")
+			args = append(args, change.RefreshID, change.ChangeType, change.OUI, change.Registry, change.BlockSize, change.CompanyName, change.PreviousCompanyName)
+		}
+
+		_, err := s.conn.Exec("INSERT INTO mac_oui_change (`refresh_id`,`change_type`,`oui`,`registry`,`block_size`,`company_name`,`previous_company_name`) VALUES "+strings.Join(placeholders, ","), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MACOUIRepository) GetRefreshes(limit int) ([]MACOUIRefresh, error) {
+	p := []MACOUIRefresh{}
+	err := s.conn.Select(&p, "SELECT * FROM mac_oui_refresh ORDER BY id DESC LIMIT ?", limit)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// GetRefresh returns the given refresh, or the latest successful refresh if
+// id is 0
+func (s *MACOUIRepository) GetRefresh(id int) (*MACOUIRefresh, error) {
+	p := MACOUIRefresh{}
+
+	var err error
+	if id == 0 {
+		err = s.conn.Get(&p, "SELECT * FROM mac_oui_refresh WHERE status = ? ORDER BY id DESC LIMIT 1", MAC_REFRESH_STATUS_SUCCESS)
+	} else {
+		err = s.conn.Get(&p, "SELECT * FROM mac_oui_refresh WHERE id = ?", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (s *MACOUIRepository) GetChanges(refreshID int) ([]MACOUIChange, error) {
+	p := []MACOUIChange{}
+	err := s.conn.Select(&p, "SELECT * FROM mac_oui_change WHERE refresh_id = ? ORDER BY change_type, oui", refreshID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// refresh retrieves every registry in full before replacing the current OUIs,
+// so a failed retrieval never removes a registry's entries, and returns the
+// changes against the current OUIs
+func (m *MAC) refresh(macOUIRepository *MACOUIRepository, refresh *MACOUIRefresh) ([]MACOUIChange, error) {
+	var ouis []MACOUI
+	seen := make(map[string]bool)
+	for _, registry := range macRegistries {
+		body, err := openSource(registry.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s registry: %w", registry.Name, err)
+		}
+
+		err = parseMACRegistry(body, registry.Bits, func(oui string, companyName string) {
+			if seen[oui] {
+				log.Debug().Str("registry", registry.Name).Str("oui", oui).Msg("Skipping duplicate OUI")
+				return
+			}
+			seen[oui] = true
+
+			ouis = append(ouis, MACOUI{OUI: oui, CompanyName: companyName, Registry: registry.Name, BlockSize: registry.Bits})
+		})
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s registry: %w", registry.Name, err)
+		}
+	}
+
+	current, err := macOUIRepository.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve current OUIs: %w", err)
+	}
+
+	if len(ouis) == 0 || float64(len(ouis)) < float64(len(current))*MAC_MIN_OUI_RATIO {
+		return nil, fmt.Errorf("refusing to replace %d OUIs with %d OUIs", len(current), len(ouis))
+	}
+
+	changes := diffMACOUIs(current, ouis)
+
+	err = macOUIRepository.Replace(ouis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace OUIs: %w", err)
+	}
+
+	refresh.Total = len(ouis)
+	for _, change := range changes {
+		switch change.ChangeType {
+		case MAC_CHANGE_ADDED:
+			refresh.Added++
+		case MAC_CHANGE_REMOVED:
+			refresh.Removed++
+		case MAC_CHANGE_RENAMED:
+			refresh.Renamed++
+		}
+	}
+
+	return changes, nil
+}
+
+// recordRefresh stores the refresh and its changes in a single transaction
+func (m *MAC) recordRefresh(conn connection.Connection, refresh *MACOUIRefresh, changes []MACOUIChange) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	macOUIRepository := NewMACOUIRepository(tx)
+
+	err = macOUIRepository.CreateRefresh(refresh)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range changes {
+		changes[i].RefreshID = refresh.ID
+	}
+
+	err = macOUIRepository.InsertChanges(changes)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// diffMACOUIs returns the OUIs added, removed and renamed between the current
+// and updated OUIs, ordered by OUI
+func diffMACOUIs(current []MACOUI, updated []MACOUI) []MACOUIChange {
+	previous := make(map[string]MACOUI, len(current))
+	for _, oui := range current {
+		previous[oui.OUI] = oui
+	}
+
+	var changes []MACOUIChange
+	for _, oui := range updated {
+		existing, ok := previous[oui.OUI]
+		delete(previous, oui.OUI)
+
+		switch {
+		case !ok:
+			changes = append(changes, MACOUIChange{ChangeType: MAC_CHANGE_ADDED, OUI: oui.OUI, Registry: oui.Registry, BlockSize: oui.BlockSize, CompanyName: oui.CompanyName})
+		case existing.CompanyName != oui.CompanyName:
+			changes = append(changes, MACOUIChange{ChangeType: MAC_CHANGE_RENAMED, OUI: oui.OUI, Registry: oui.Registry, BlockSize: oui.BlockSize, CompanyName: oui.CompanyName, PreviousCompanyName: existing.CompanyName})
+		}
+	}
+
+	for _, oui := range previous {
+		changes = append(changes, MACOUIChange{ChangeType: MAC_CHANGE_REMOVED, OUI: oui.OUI, Registry: oui.Registry, BlockSize: oui.BlockSize, PreviousCompanyName: oui.CompanyName})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].OUI < changes[j].OUI
+	})
+
+	return changes
+}
@@ -0,0 +1,713 @@
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const RDAP_TIMEOUT = 15 * time.Second
+const RDAP_MAX_BODY_SIZE = 4 << 20
+
+var errRDAPNotFound = errors.New("object not found")
+var errRDAPInsecureLink = errors.New("refusing to follow non-https RDAP link")
+var errRDAPForbiddenAddress = errors.New("refusing to follow RDAP link to special-purpose address")
+
+var rdapClient = &http.Client{Timeout: RDAP_TIMEOUT}
+
+// RDAPBootstrap holds the locations of the IANA bootstrap registries, as URLs
+// or local files
+type RDAPBootstrap struct {
+	DNS  string
+	IPv4 string
+	IPv6 string
+	ASN  string
+}
+
+type rdapBootstrapFile struct {
+	Services [][][]string `json:"services"`
+}
+
+type rdapASNRange struct {
+	Start   uint32
+	End     uint32
+	Servers []string
+}
+
+type rdapIndex struct {
+	dns      map[string][]string
+	ip       *prefixtrie.Trie[[]string]
+	asns     []rdapASNRange
+	loadedAt time.Time
+}
+
+// domainServers returns the servers for the longest matching suffix of name
+func (i *rdapIndex) domainServers(name string) []string {
+	labels := strings.Split(name, ".")
+	for n := range labels {
+		if servers, ok := i.dns[strings.Join(labels[n:], ".")]; ok {
+			return servers
+		}
+	}
+
+	return nil
+}
+
+// ipServers returns the servers for the most specific covering prefix
+func (i *rdapIndex) ipServers(prefix netip.Prefix) []string {
+	matches := i.ip.Covering(prefix)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	return matches[len(matches)-1].Values[0]
+}
+
+func (i *rdapIndex) asnServers(asn uint32) []string {
+	for _, r := range i.asns {
+		if asn >= r.Start && asn <= r.End {
+			return r.Servers
+		}
+	}
+
+	return nil
+}
+
+type RDAP struct {
+	bootstrap      RDAPBootstrap
+	index          atomic.Pointer[rdapIndex]
+	indexMutex     sync.Mutex
+	relatedClient  *http.Client
+	specialPurpose *util.SpecialPurposeRegistry
+}
+
+func NewRDAP(bootstrap RDAPBootstrap) *RDAP {
+	t := &RDAP{bootstrap: bootstrap}
+	t.relatedClient = t.newRelatedClient()
+	return t
+}
+
+// WithSpecialPurpose refuses to follow links to addresses which aren't
+// globally reachable, rather than only private and local addresses
+func (t *RDAP) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *RDAP {
+	t.specialPurpose = registry
+	return t
+}
+
+// newRelatedClient returns a client for following links within responses,
+// which are controlled by third parties, so only connects over https to
+// globally reachable addresses. Addresses are checked once resolved, when
+// connecting, so a link can't be pointed elsewhere by its DNS
+func (t *RDAP) newRelatedClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: RDAP_TIMEOUT,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !t.globallyReachable(addrPort.Addr()) {
+				return errRDAPForbiddenAddress
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   RDAP_TIMEOUT,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if request.URL.Scheme != "https" {
+				return errRDAPInsecureLink
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			return nil
+		},
+	}
+}
+
+func (t *RDAP) globallyReachable(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if t.specialPurpose == nil {
+		return addr.IsGlobalUnicast() && !addr.IsPrivate()
+	}
+
+	block, ok := t.specialPurpose.Lookup(addr)
+	return !ok || block.Global
+}
+
+func (t *RDAP) Paths() []string {
+	return []string{
+		"/rdap",
+		"/rdap/{query}",
+		"/rdap/{query}/{length:[0-9]+}",
+	}
+}
+
+func (t *RDAP) Method() string {
+	return "GET"
+}
+
+func (t *RDAP) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query, ok := vars["query"]
+	if !ok {
+		query = util.GetSourceIPAddress(r)
+	}
+
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	index, err := t.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []string
+	var path string
+	if prefix, err := parsePrefixOrAddr(query); err == nil {
+		query = prefix.String()
+		if prefix.IsSingleIP() {
+			query = prefix.Addr().String()
+		}
+		servers, path = index.ipServers(prefix), "ip/"+query
+	} else if asn, err := parseASN(query); err == nil {
+		query = fmt.Sprintf("AS%d", asn)
+		servers, path = index.asnServers(asn), fmt.Sprintf("autnum/%d", asn)
+	} else {
+		query = strings.TrimSuffix(strings.ToLower(query), ".")
+		if !strings.Contains(query, ".") {
+			return nil, errors.New("invalid query - must be a domain, IP address, prefix or ASN")
+		}
+		servers, path = index.domainServers(query), "domain/"+query
+	}
+
+	server := rdapPreferredServer(servers)
+	if server == "" {
+		return nil, errors.New("no RDAP server found for query")
+	}
+
+	url := strings.TrimSuffix(server, "/") + "/" + path
+	object, err := rdapFetch(rdapClient, url)
+	if err != nil {
+		if errors.Is(err, errRDAPNotFound) {
+			return nil, err
+		}
+
+		log.Error().Err(err).Str("url", url).Msg("Failed to query RDAP server")
+		return nil, errors.New("failed to query RDAP server")
+	}
+
+	response := &RDAPResponseData{Query: query, Sources: []string{url}}
+	objects := []*rdapObject{object}
+
+	// Registries often hold only a summary, linking to the sponsoring
+	// registrar's more complete record
+	if related := object.related(url); related != "" {
+		relatedObject, err := rdapFetch(t.relatedClient, related)
+		if err != nil {
+			log.Debug().Err(err).Str("url", related).Msg("Failed to follow RDAP link")
+		} else {
+			objects = append(objects, relatedObject)
+			response.Sources = append(response.Sources, related)
+		}
+	}
+
+	response.normalise(objects)
+
+	return NewToolResponse(response), nil
+}
+
+func (t *RDAP) Cron() CronSpec {
+	return CronSpec{Cron: "0 5 * * *", Func: t.cronWork}
+}
+
+func (t *RDAP) cronWork() {
+	log.Info().Msg("RDAP: Starting cron")
+
+	t.indexMutex.Lock()
+	err := t.loadIndex()
+	t.indexMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("RDAP: Load failed, keeping current bootstrap")
+		return
+	}
+
+	log.Info().Msg("RDAP: Cron completed")
+}
+
+func (t *RDAP) getIndex() (*rdapIndex, error) {
+	if index := t.index.Load(); index != nil {
+		return index, nil
+	}
+
+	t.indexMutex.Lock()
+	defer t.indexMutex.Unlock()
+
+	if index := t.index.Load(); index != nil {
+		return index, nil
+	}
+
+	err := t.loadIndex()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load RDAP bootstrap")
+		return nil, errors.New("RDAP bootstrap data unavailable")
+	}
+
+	return t.index.Load(), nil
+}
+
+// loadIndex loads all bootstrap registries, replacing the current index only
+// if every registry loads successfully
+func (t *RDAP) loadIndex() error {
+	index := &rdapIndex{
+		dns:      make(map[string][]string),
+		ip:       prefixtrie.New[[]string](),
+		loadedAt: time.Now().UTC(),
+	}
+
+	err := t.loadBootstrap(t.bootstrap.DNS, func(entry string, servers []string) error {
+		index.dns[strings.TrimSuffix(strings.ToLower(entry), ".")] = servers
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load DNS bootstrap: %w", err)
+	}
+
+	for _, location := range []string{t.bootstrap.IPv4, t.bootstrap.IPv6} {
+		err := t.loadBootstrap(location, func(entry string, servers []string) error {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return err
+			}
+			index.ip.Insert(prefix.Masked(), servers)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load IP bootstrap: %w", err)
+		}
+	}
+
+	err = t.loadBootstrap(t.bootstrap.ASN, func(entry string, servers []string) error {
+		start, end, ok := strings.Cut(entry, "-")
+		if !ok {
+			end = start
+		}
+
+		startASN, err := parseASN(start)
+		if err != nil {
+			return err
+		}
+		endASN, err := parseASN(end)
+		if err != nil {
+			return err
+		}
+
+		index.asns = append(index.asns, rdapASNRange{Start: startASN, End: endASN, Servers: servers})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load ASN bootstrap: %w", err)
+	}
+
+	t.index.Store(index)
+
+	log.Debug().Int("tlds", len(index.dns)).Int("prefixes", index.ip.Len()).Int("asn_ranges", len(index.asns)).Msg("Loaded RDAP bootstrap")
+	return nil
+}
+
+// loadBootstrap parses an RFC 9224 bootstrap registry, calling fn with each
+// entry and its servers
+func (t *RDAP) loadBootstrap(location string, fn func(entry string, servers []string) error) error {
+	if location == "" {
+		return errors.New("no location configured")
+	}
+
+	body, err := openSource(location)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	var bootstrap rdapBootstrapFile
+	if err := json.NewDecoder(body).Decode(&bootstrap); err != nil {
+		return err
+	}
+
+	if len(bootstrap.Services) == 0 {
+		return errors.New("no services found")
+	}
+
+	for _, service := range bootstrap.Services {
+		if len(service) < 2 || len(service[1]) == 0 {
+			continue
+		}
+
+		for _, entry := range service[0] {
+			if err := fn(entry, service[1]); err != nil {
+				return fmt.Errorf("invalid entry %s: %w", entry, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rdapPreferredServer returns the first HTTPS server, falling back to the
+// first server listed
+func rdapPreferredServer(servers []string) string {
+	for _, server := range servers {
+		if strings.HasPrefix(server, "https://") {
+			return server
+		}
+	}
+
+	if len(servers) > 0 {
+		return servers[0]
+	}
+
+	return ""
+}
+
+func rdapFetch(client *http.Client, url string) (*rdapObject, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/rdap+json, application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, errRDAPNotFound
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	var object rdapObject
+	if err := json.NewDecoder(io.LimitReader(response.Body, RDAP_MAX_BODY_SIZE)).Decode(&object); err != nil {
+		return nil, err
+	}
+
+	return &object, nil
+}
+
+type rdapEvent struct {
+	EventAction string `json:"eventAction"`
+	EventDate   string `json:"eventDate"`
+}
+
+type rdapLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+	Type string `json:"type"`
+}
+
+type rdapPublicID struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+}
+
+type rdapEntity struct {
+	Handle     string            `json:"handle"`
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	PublicIDs  []rdapPublicID    `json:"publicIds"`
+	Entities   []rdapEntity      `json:"entities"`
+}
+
+type rdapObject struct {
+	ObjectClassName string       `json:"objectClassName"`
+	Handle          string       `json:"handle"`
+	LDHName         string       `json:"ldhName"`
+	Name            string       `json:"name"`
+	StartAddress    string       `json:"startAddress"`
+	EndAddress      string       `json:"endAddress"`
+	StartAutnum     uint32       `json:"startAutnum"`
+	EndAutnum       uint32       `json:"endAutnum"`
+	Country         string       `json:"country"`
+	Status          []string     `json:"status"`
+	Events          []rdapEvent  `json:"events"`
+	Entities        []rdapEntity `json:"entities"`
+	Links           []rdapLink   `json:"links"`
+	Nameservers     []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+}
+
+// related returns the first https link to an RDAP record other than the
+// object itself
+func (o *rdapObject) related(self string) string {
+	for _, link := range o.Links {
+		if link.Rel != "related" || link.Href == "" || strings.EqualFold(link.Href, self) {
+			continue
+		}
+
+		if u, err := url.Parse(link.Href); err != nil || u.Scheme != "https" || u.Host == "" {
+			continue
+		}
+
+		if link.Type == "" || strings.Contains(link.Type, "rdap+json") {
+			return link.Href
+		}
+	}
+
+	return ""
+}
+
+// rdapContact parses an entity's jCard (RFC 7095)
+func rdapContact(entity rdapEntity) RDAPContact {
+	contact := RDAPContact{Handle: entity.Handle, Roles: entity.Roles}
+	for _, id := range entity.PublicIDs {
+		if id.Type == "IANA Registrar ID" {
+			contact.IANAID = id.Identifier
+		}
+	}
+
+	if len(entity.VCardArray) < 2 {
+		return contact
+	}
+
+	var properties [][]json.RawMessage
+	if err := json.Unmarshal(entity.VCardArray[1], &properties); err != nil {
+		return contact
+	}
+
+	for _, property := range properties {
+		if len(property) < 4 {
+			continue
+		}
+
+		var name, value string
+		json.Unmarshal(property[0], &name)
+		if err := json.Unmarshal(property[3], &value); err != nil {
+			continue
+		}
+
+		switch name {
+		case "fn":
+			contact.Name = value
+		case "org":
+			contact.Organisation = value
+		case "email":
+			if contact.Email == "" {
+				contact.Email = value
+			}
+		case "tel":
+			if contact.Phone == "" {
+				contact.Phone = strings.TrimPrefix(value, "tel:")
+			}
+		}
+	}
+
+	return contact
+}
+
+// rdapWalkEntities calls fn with each entity, including nested entities
+func rdapWalkEntities(entities []rdapEntity, fn func(entity rdapEntity)) {
+	for _, entity := range entities {
+		fn(entity)
+		rdapWalkEntities(entity.Entities, fn)
+	}
+}
+
+type RDAPContact struct {
+	Handle       string   `json:"handle,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Organisation string   `json:"organisation,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Phone        string   `json:"phone,omitempty"`
+	IANAID       string   `json:"iana_id,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+}
+
+func (c *RDAPContact) String() string {
+	var parts []string
+	for _, part := range []string{c.Name, c.Organisation} {
+		if part != "" && !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 && c.Handle != "" {
+		parts = append(parts, c.Handle)
+	}
+	if c.Email != "" {
+		parts = append(parts, "<"+c.Email+">")
+	}
+	if c.Phone != "" {
+		parts = append(parts, c.Phone)
+	}
+	if c.IANAID != "" {
+		parts = append(parts, "(IANA ID "+c.IANAID+")")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+type RDAPEvents struct {
+	Created string `json:"created,omitempty"`
+	Updated string `json:"updated,omitempty"`
+	Expires string `json:"expires,omitempty"`
+}
+
+type RDAPResponseData struct {
+	Query       string        `json:"query"`
+	ObjectClass string        `json:"object_class"`
+	Handle      string        `json:"handle,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	Range       string        `json:"range,omitempty"`
+	Country     string        `json:"country,omitempty"`
+	Registrant  *RDAPContact  `json:"registrant,omitempty"`
+	Registrar   *RDAPContact  `json:"registrar,omitempty"`
+	Abuse       []RDAPContact `json:"abuse"`
+	Events      RDAPEvents    `json:"events"`
+	Status      []string      `json:"status"`
+	Nameservers []string      `json:"nameservers,omitempty"`
+	Sources     []string      `json:"sources"`
+}
+
+// normalise populates the response from the registry's record and any
+// related records. The registry is authoritative for status, nameservers and
+// dates, while contacts are taken from the most detailed record available
+func (r *RDAPResponseData) normalise(objects []*rdapObject) {
+	primary := objects[0]
+
+	r.ObjectClass = primary.ObjectClassName
+	r.Handle = primary.Handle
+	r.Name = primary.Name
+	if primary.LDHName != "" {
+		r.Name = strings.ToLower(primary.LDHName)
+	}
+	r.Country = primary.Country
+	r.Status = primary.Status
+	if r.Status == nil {
+		r.Status = []string{}
+	}
+
+	switch {
+	case primary.StartAddress != "":
+		r.Range = primary.StartAddress + " - " + primary.EndAddress
+	case primary.StartAutnum != 0:
+		r.Range = fmt.Sprintf("AS%d - AS%d", primary.StartAutnum, primary.EndAutnum)
+	}
+
+	for _, nameserver := range primary.Nameservers {
+		r.Nameservers = append(r.Nameservers, strings.ToLower(nameserver.LDHName))
+	}
+
+	for _, object := range objects {
+		for _, event := range object.Events {
+			switch event.EventAction {
+			case "registration":
+				if r.Events.Created == "" {
+					r.Events.Created = event.EventDate
+				}
+			case "last changed":
+				if r.Events.Updated == "" {
+					r.Events.Updated = event.EventDate
+				}
+			case "expiration":
+				if r.Events.Expires == "" {
+					r.Events.Expires = event.EventDate
+				}
+			}
+		}
+	}
+
+	r.Abuse = []RDAPContact{}
+	for i := len(objects) - 1; i >= 0; i-- {
+		rdapWalkEntities(objects[i].Entities, func(entity rdapEntity) {
+			contact := rdapContact(entity)
+			if slices.Contains(entity.Roles, "registrant") && r.Registrant == nil {
+				r.Registrant = &contact
+			}
+			if slices.Contains(entity.Roles, "registrar") && r.Registrar == nil {
+				r.Registrar = &contact
+			}
+			if slices.Contains(entity.Roles, "abuse") && (contact.Email != "" || contact.Phone != "") {
+				for _, existing := range r.Abuse {
+					if existing.Email == contact.Email && existing.Phone == contact.Phone {
+						return
+					}
+				}
+				r.Abuse = append(r.Abuse, contact)
+			}
+		})
+	}
+}
+
+func (r *RDAPResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *RDAPResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *RDAPResponseData) render(colour bool) string {
+	pairs := []keyValue{
+		{Key: "Query:", Value: r.Query},
+		{Key: "Object:", Value: r.ObjectClass},
+	}
+
+	optional := func(key string, value string) {
+		if value != "" {
+			pairs = append(pairs, keyValue{Key: key, Value: value})
+		}
+	}
+
+	optional("Handle:", r.Handle)
+	optional("Name:", r.Name)
+	optional("Range:", r.Range)
+	optional("Country:", r.Country)
+	if r.Registrant != nil {
+		optional("Registrant:", r.Registrant.String())
+	}
+	if r.Registrar != nil {
+		optional("Registrar:", r.Registrar.String())
+	}
+	for _, abuse := range r.Abuse {
+		optional("Abuse:", abuse.String())
+	}
+	optional("Created:", r.Events.Created)
+	optional("Updated:", r.Events.Updated)
+	optional("Expires:", r.Events.Expires)
+	optional("Status:", strings.Join(r.Status, ", "))
+	optional("Nameservers:", strings.Join(r.Nameservers, ", "))
+	for _, source := range r.Sources {
+		pairs = append(pairs, keyValue{Key: "Source:", Value: colourise(source, ANSI_YELLOW, colour)})
+	}
+
+	return renderKeyValues(13, colour, pairs...)
+}
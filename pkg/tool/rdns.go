@@ -4,18 +4,28 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"net/netip"
 
 	"github.com/0x4c6565/lee.io/internal/pkg/util"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
 
-type RDNS struct{}
+type RDNS struct {
+	specialPurpose *util.SpecialPurposeRegistry
+}
 
 func NewRDNS() *RDNS {
 	return &RDNS{}
 }
 
+// WithSpecialPurpose short-circuits lookups for addresses which aren't
+// globally reachable
+func (i *RDNS) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *RDNS {
+	i.specialPurpose = registry
+	return i
+}
+
 func (i *RDNS) Paths() []string {
 	return []string{
 		"/rdns",
@@ -28,12 +38,56 @@ func (i *RDNS) Method() string {
 }
 
 func (i *RDNS) Handle(r *http.Request) (*ToolResponse, error) {
+	if r.Method == http.MethodPost {
+		queries, err := parseBulkQueries(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewToolResponse(runBulk(queries, func(query string) (ToolResponseData, error) {
+			return i.lookup(query)
+		})), nil
+	}
+
 	vars := mux.Vars(r)
 
-	ip := net.ParseIP(util.GetSourceIPAddress(r))
-	hostVar, ok := vars["host"]
-	if ok {
-		lookupResp, err := net.LookupIP(hostVar)
+	host, ok := vars["host"]
+	if !ok {
+		host = util.GetSourceIPAddress(r)
+	}
+
+	response, err := i.lookup(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewToolResponse(response), nil
+}
+
+func (i *RDNS) HandleStream(r *http.Request, emit ToolStreamFunc) error {
+	if r.Method != http.MethodPost {
+		response, err := i.Handle(r)
+		if err != nil {
+			return err
+		}
+
+		return emit(response.Data)
+	}
+
+	queries, err := parseBulkQueries(r)
+	if err != nil {
+		return err
+	}
+
+	return streamBulk(queries, func(query string) (ToolResponseData, error) {
+		return i.lookup(query)
+	}, emit)
+}
+
+func (i *RDNS) lookup(host string) (*ToolResponseString, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		lookupResp, err := net.LookupIP(host)
 		if err != nil {
 			log.Error().Err(err).Send()
 			return nil, errors.New("failed to lookup host")
@@ -44,6 +98,11 @@ func (i *RDNS) Handle(r *http.Request) (*ToolResponse, error) {
 		ip = lookupResp[0]
 	}
 
+	addr, _ := netip.AddrFromSlice(ip)
+	if err := specialPurposeError(i.specialPurpose, addr); err != nil {
+		return nil, err
+	}
+
 	rdns, err := net.LookupAddr(ip.String())
 	if err != nil {
 		log.Error().Err(err).Send()
@@ -54,7 +113,5 @@ func (i *RDNS) Handle(r *http.Request) (*ToolResponse, error) {
 		return nil, errors.New("failed to lookup rDNS - no rDNS records")
 	}
 
-	return NewToolResponse(
-		NewToolResponseString(rdns[0]),
-	), nil
+	return NewToolResponseString(rdns[0]), nil
 }
@@ -0,0 +1,337 @@
+package tool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// ReputationList is a configured IP/CIDR threat list
+type ReputationList struct {
+	Name        string
+	Description string
+	Location    string
+}
+
+type reputationEntry struct {
+	Prefix    netip.Prefix
+	Reference string
+}
+
+// reputationListState is the last successful load of a list
+type reputationListState struct {
+	list     ReputationList
+	trie     *prefixtrie.Trie[reputationEntry]
+	count    int
+	loadedAt time.Time
+}
+
+type Reputation struct {
+	lists     []ReputationList
+	states    atomic.Pointer[[]*reputationListState]
+	loadMutex sync.Mutex
+}
+
+func NewReputation(lists []ReputationList) *Reputation {
+	return &Reputation{lists: lists}
+}
+
+func (t *Reputation) Paths() []string {
+	return []string{
+		"/reputation",
+		"/reputation/{query}",
+		"/reputation/{query}/{length:[0-9]+}",
+	}
+}
+
+func (t *Reputation) Method() string {
+	return "GET"
+}
+
+func (t *Reputation) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query, ok := vars["query"]
+	if !ok {
+		query = util.GetSourceIPAddress(r)
+	}
+
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	prefix, err := parsePrefixOrAddr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := t.getStates()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReputationResponseData{
+		Query:   prefix.String(),
+		Matches: []ReputationResponseDataItem{},
+	}
+	if prefix.IsSingleIP() {
+		response.Query = prefix.Addr().String()
+	}
+
+	for _, state := range states {
+		response.Lists = append(response.Lists, ReputationResponseDataList{
+			Name:        state.list.Name,
+			Description: state.list.Description,
+			Entries:     state.count,
+			LoadedAt:    state.loadedAt,
+		})
+
+		for _, match := range state.trie.Covering(prefix) {
+			for _, entry := range match.Values {
+				listed := entry.Prefix.String()
+				if entry.Prefix.IsSingleIP() {
+					listed = entry.Prefix.Addr().String()
+				}
+
+				response.Matches = append(response.Matches, ReputationResponseDataItem{
+					List:        state.list.Name,
+					Description: state.list.Description,
+					Prefix:      listed,
+					Reference:   entry.Reference,
+					LoadedAt:    state.loadedAt,
+				})
+			}
+		}
+	}
+	response.Listed = len(response.Matches) > 0
+
+	return NewToolResponse(response), nil
+}
+
+// Start loads the lists, which are too large to download on the request path
+func (t *Reputation) Start() {
+	go t.cronWork()
+}
+
+func (t *Reputation) Cron() CronSpec {
+	return CronSpec{Cron: "45 * * * *", Func: t.cronWork}
+}
+
+func (t *Reputation) cronWork() {
+	if !t.loadMutex.TryLock() {
+		log.Info().Msg("Reputation: Load already in progress")
+		return
+	}
+
+	log.Info().Msg("Reputation: Starting cron")
+
+	err := t.loadLists()
+	t.loadMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("Reputation: Load failed")
+		return
+	}
+
+	log.Info().Msg("Reputation: Cron completed")
+}
+
+func (t *Reputation) getStates() ([]*reputationListState, error) {
+	states := t.states.Load()
+	if states == nil {
+		return nil, errors.New("reputation data unavailable")
+	}
+
+	return *states, nil
+}
+
+// loadLists loads each list independently, keeping the previous load of any
+// list which fails so that one unavailable list doesn't affect the others
+func (t *Reputation) loadLists() error {
+	if len(t.lists) == 0 {
+		return errors.New("no reputation lists configured")
+	}
+
+	previous := make(map[string]*reputationListState)
+	if states := t.states.Load(); states != nil {
+		for _, state := range *states {
+			previous[state.list.Name] = state
+		}
+	}
+
+	var states []*reputationListState
+	for _, list := range t.lists {
+		state, err := t.loadList(list)
+		if err != nil {
+			log.Error().Err(err).Str("list", list.Name).Msg("Failed to load reputation list")
+			if state, ok := previous[list.Name]; ok {
+				states = append(states, state)
+			}
+			continue
+		}
+
+		log.Debug().Str("list", list.Name).Int("entries", state.count).Msg("Loaded reputation list")
+		states = append(states, state)
+	}
+
+	if len(states) == 0 {
+		return errors.New("no reputation lists loaded")
+	}
+
+	t.states.Store(&states)
+	return nil
+}
+
+func (t *Reputation) loadList(list ReputationList) (*reputationListState, error) {
+	body, err := openSource(list.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	entries, err := parseReputationList(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no entries found")
+	}
+
+	state := &reputationListState{
+		list:     list,
+		trie:     prefixtrie.New[reputationEntry](),
+		count:    len(entries),
+		loadedAt: time.Now().UTC(),
+	}
+	for _, entry := range entries {
+		state.trie.Insert(entry.Prefix, entry)
+	}
+
+	return state, nil
+}
+
+// parseReputationList parses an address or prefix per line, with anything
+// after a # or ; treated as a comment (Spamhaus DROP, FireHOL netsets, Tor
+// exit lists). Spamhaus JSON lines are also supported, with the SBL ID kept
+// as the entry's reference
+func parseReputationList(r io.Reader) ([]reputationEntry, error) {
+	var entries []reputationEntry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		var field, reference string
+		if strings.HasPrefix(line, "{") {
+			var record struct {
+				CIDR  string `json:"cidr"`
+				SBLID string `json:"sblid"`
+			}
+			if err := json.Unmarshal([]byte(line), &record); err != nil || record.CIDR == "" {
+				continue
+			}
+			field, reference = record.CIDR, record.SBLID
+		} else {
+			line, comment, _ := strings.Cut(line, ";")
+			line, _, _ = strings.Cut(line, "#")
+
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			field, reference = fields[0], strings.TrimSpace(comment)
+		}
+
+		prefix, err := parsePrefixOrAddr(field)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, reputationEntry{Prefix: prefix, Reference: reference})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+type ReputationResponseDataItem struct {
+	List        string    `json:"list"`
+	Description string    `json:"description,omitempty"`
+	Prefix      string    `json:"prefix"`
+	Reference   string    `json:"reference,omitempty"`
+	LoadedAt    time.Time `json:"loaded_at"`
+}
+
+type ReputationResponseDataList struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Entries     int       `json:"entries"`
+	LoadedAt    time.Time `json:"loaded_at"`
+}
+
+type ReputationResponseData struct {
+	Query   string                       `json:"query"`
+	Listed  bool                         `json:"listed"`
+	Matches []ReputationResponseDataItem `json:"matches"`
+	Lists   []ReputationResponseDataList `json:"lists"`
+}
+
+func (r *ReputationResponseData) Header() []string {
+	return []string{"list", "description", "prefix", "reference", "loaded_at"}
+}
+
+func (r *ReputationResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, match := range r.Matches {
+		rows = append(rows, []string{match.List, match.Description, match.Prefix, match.Reference, match.LoadedAt.Format(time.RFC3339)})
+	}
+
+	return rows
+}
+
+func (r *ReputationResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *ReputationResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *ReputationResponseData) render(colour bool) string {
+	listed := colourise("false", ANSI_GREEN, colour)
+	if r.Listed {
+		listed = colourise("true", ANSI_RED, colour)
+	}
+
+	var lists []string
+	for _, list := range r.Lists {
+		lists = append(lists, list.Name)
+	}
+
+	output := renderKeyValues(10, colour,
+		keyValue{Key: "Query:", Value: r.Query},
+		keyValue{Key: "Listed:", Value: listed},
+		keyValue{Key: "Checked:", Value: strings.Join(lists, ", ")},
+	)
+	if len(r.Matches) == 0 {
+		return output
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
@@ -0,0 +1,435 @@
+package tool
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x4c6565/lee.io/internal/pkg/prefixtrie"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	RPKI_STATE_VALID     = "valid"
+	RPKI_STATE_INVALID   = "invalid"
+	RPKI_STATE_NOT_FOUND = "not-found"
+)
+
+const (
+	RPKI_REASON_WRONG_ASN    = "wrong-asn"
+	RPKI_REASON_TOO_SPECIFIC = "too-specific"
+)
+
+type RPKIROA struct {
+	ASN         uint32 `json:"asn"`
+	Prefix      string `json:"prefix"`
+	MaxLength   int    `json:"max_length"`
+	TrustAnchor string `json:"trust_anchor"`
+}
+
+// rpkiASN accepts ASNs as either numbers (rpki-client) or AS prefixed strings
+// (Routinator)
+type rpkiASN uint32
+
+func (a *rpkiASN) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		v = string(data)
+	}
+
+	asn, err := parseASN(v)
+	if err != nil {
+		return err
+	}
+
+	*a = rpkiASN(asn)
+	return nil
+}
+
+type rpkiExport struct {
+	ROAs []struct {
+		ASN       rpkiASN `json:"asn"`
+		Prefix    string  `json:"prefix"`
+		MaxLength int     `json:"maxLength"`
+		TA        string  `json:"ta"`
+	} `json:"roas"`
+}
+
+// rpkiIndex holds validated ROA payloads keyed by prefix
+type rpkiIndex struct {
+	trie     *prefixtrie.Trie[RPKIROA]
+	count    int
+	loadedAt time.Time
+}
+
+func newRPKIIndex(roas []RPKIROA) *rpkiIndex {
+	index := &rpkiIndex{
+		trie:     prefixtrie.New[RPKIROA](),
+		loadedAt: time.Now().UTC(),
+	}
+
+	for _, roa := range roas {
+		prefix, err := netip.ParsePrefix(roa.Prefix)
+		if err != nil {
+			log.Warn().Err(err).Str("prefix", roa.Prefix).Msg("Skipping invalid ROA")
+			continue
+		}
+
+		if roa.MaxLength < prefix.Bits() {
+			roa.MaxLength = prefix.Bits()
+		}
+
+		index.trie.Insert(prefix, roa)
+		index.count++
+	}
+
+	return index
+}
+
+// validate performs route origin validation of the route per RFC 6811,
+// returning the state, reason for invalid routes and all covering ROAs
+func (i *rpkiIndex) validate(prefix netip.Prefix, asn uint32) (string, string, []RPKIROA) {
+	var covering []RPKIROA
+	for _, match := range i.trie.Covering(prefix) {
+		covering = append(covering, match.Values...)
+	}
+
+	if len(covering) == 0 {
+		return RPKI_STATE_NOT_FOUND, "", covering
+	}
+
+	reason := RPKI_REASON_WRONG_ASN
+	for _, roa := range covering {
+		// AS0 ROAs assert that the prefix must never be originated
+		if roa.ASN != asn || roa.ASN == 0 {
+			continue
+		}
+
+		if prefix.Bits() <= roa.MaxLength {
+			return RPKI_STATE_VALID, "", covering
+		}
+
+		reason = RPKI_REASON_TOO_SPECIFIC
+	}
+
+	return RPKI_STATE_INVALID, reason, covering
+}
+
+type RPKI struct {
+	source     string
+	index      atomic.Pointer[rpkiIndex]
+	indexMutex sync.Mutex
+}
+
+func NewRPKI(source string) *RPKI {
+	return &RPKI{source: source}
+}
+
+func (t *RPKI) Paths() []string {
+	return []string{
+		"/rpki/{prefix}/{asn}",
+		"/rpki/{prefix}/{length:[0-9]+}/{asn}",
+	}
+}
+
+func (t *RPKI) Method() string {
+	return "GET"
+}
+
+func (t *RPKI) Handle(r *http.Request) (*ToolResponse, error) {
+	vars := mux.Vars(r)
+
+	query := vars["prefix"]
+	if length, ok := vars["length"]; ok {
+		query = query + "/" + length
+	}
+
+	prefix, err := parsePrefixOrAddr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	asn, err := parseASN(vars["asn"])
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := t.getIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	state, reason, roas := index.validate(prefix, asn)
+
+	return NewToolResponse(&RPKIResponseData{
+		Prefix:   prefix.String(),
+		ASN:      asn,
+		State:    state,
+		Reason:   reason,
+		ROAs:     roas,
+		LoadedAt: index.loadedAt,
+	}), nil
+}
+
+// Start loads the ROAs used to validate BGP results
+func (t *RPKI) Start() {
+	go t.cronWork()
+}
+
+func (t *RPKI) Cron() CronSpec {
+	return CronSpec{Cron: "15 * * * *", Func: t.cronWork}
+}
+
+func (t *RPKI) cronWork() {
+	if !t.indexMutex.TryLock() {
+		log.Info().Msg("RPKI: Load already in progress")
+		return
+	}
+
+	log.Info().Msg("RPKI: Starting cron")
+
+	err := t.loadIndex()
+	t.indexMutex.Unlock()
+	if err != nil {
+		log.Error().Err(err).Msg("RPKI: Load failed, keeping current ROAs")
+		return
+	}
+
+	log.Info().Msg("RPKI: Cron completed")
+}
+
+// validate returns the RPKI state of the route, or an empty state where no
+// ROAs have been loaded
+func (t *RPKI) validate(prefix netip.Prefix, asn uint32) (string, string) {
+	index := t.index.Load()
+	if index == nil {
+		return "", ""
+	}
+
+	state, reason, _ := index.validate(prefix, asn)
+	return state, reason
+}
+
+func (t *RPKI) getIndex() (*rpkiIndex, error) {
+	if index := t.index.Load(); index != nil {
+		return index, nil
+	}
+
+	t.indexMutex.Lock()
+	defer t.indexMutex.Unlock()
+
+	if index := t.index.Load(); index != nil {
+		return index, nil
+	}
+
+	err := t.loadIndex()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load RPKI ROAs")
+		return nil, errors.New("RPKI data unavailable")
+	}
+
+	return t.index.Load(), nil
+}
+
+func (t *RPKI) loadIndex() error {
+	if t.source == "" {
+		return errors.New("no RPKI source configured")
+	}
+
+	body, err := openSource(t.source)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	roas, err := parseROAs(body)
+	if err != nil {
+		return err
+	}
+
+	if len(roas) == 0 {
+		return errors.New("no ROAs found in source")
+	}
+
+	index := newRPKIIndex(roas)
+	t.index.Store(index)
+
+	log.Debug().Int("roas", index.count).Msg("Loaded RPKI ROAs")
+	return nil
+}
+
+// parseROAs parses validated ROA payloads in either the JSON export format
+// shared by rpki-client and Routinator, or Routinator's CSV format
+func parseROAs(r io.Reader) ([]RPKIROA, error) {
+	reader := bufio.NewReader(r)
+
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	if b, _ := reader.Peek(1); b[0] == '{' {
+		return parseROAsJSON(reader)
+	}
+
+	return parseROAsCSV(reader)
+}
+
+func parseROAsJSON(r io.Reader) ([]RPKIROA, error) {
+	var export rpkiExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+
+	var roas []RPKIROA
+	for _, roa := range export.ROAs {
+		roas = append(roas, RPKIROA{
+			ASN:         uint32(roa.ASN),
+			Prefix:      roa.Prefix,
+			MaxLength:   roa.MaxLength,
+			TrustAnchor: roa.TA,
+		})
+	}
+
+	return roas, nil
+}
+
+func parseROAsCSV(r io.Reader) ([]RPKIROA, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var roas []RPKIROA
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(record) < 3 {
+			continue
+		}
+
+		// Skip the header row
+		asn, err := parseASN(record[0])
+		if err != nil {
+			continue
+		}
+
+		maxLength, err := strconv.Atoi(strings.TrimSpace(record[2]))
+		if err != nil {
+			continue
+		}
+
+		roa := RPKIROA{
+			ASN:       asn,
+			Prefix:    strings.TrimSpace(record[1]),
+			MaxLength: maxLength,
+		}
+		if len(record) > 3 {
+			roa.TrustAnchor = strings.TrimSpace(record[3])
+		}
+
+		roas = append(roas, roa)
+	}
+
+	return roas, nil
+}
+
+// parsePrefixOrAddr parses a prefix, treating a bare address as a host route
+func parsePrefixOrAddr(query string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(query); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(query)
+	if err != nil {
+		return netip.Prefix{}, errors.New("invalid prefix")
+	}
+
+	return prefix.Masked(), nil
+}
+
+type RPKIResponseData struct {
+	Prefix   string    `json:"prefix"`
+	ASN      uint32    `json:"asn"`
+	State    string    `json:"state"`
+	Reason   string    `json:"reason,omitempty"`
+	ROAs     []RPKIROA `json:"roas"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+func (r *RPKIResponseData) Header() []string {
+	return []string{"asn", "prefix", "max_length", "trust_anchor"}
+}
+
+func (r *RPKIResponseData) Rows() [][]string {
+	var rows [][]string
+	for _, roa := range r.ROAs {
+		rows = append(rows, []string{fmt.Sprintf("AS%d", roa.ASN), roa.Prefix, strconv.Itoa(roa.MaxLength), roa.TrustAnchor})
+	}
+
+	return rows
+}
+
+func (r *RPKIResponseData) String() string {
+	return r.render(false)
+}
+
+func (r *RPKIResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *RPKIResponseData) render(colour bool) string {
+	output := renderKeyValues(10, colour,
+		keyValue{Key: "Prefix", Value: r.Prefix},
+		keyValue{Key: "ASN", Value: fmt.Sprintf("AS%d", r.ASN)},
+		keyValue{Key: "State", Value: colourRPKIState(formatRPKIState(r.State, r.Reason), r.State, colour)},
+		keyValue{Key: "Loaded", Value: r.LoadedAt.Format(time.RFC3339)},
+	)
+
+	if len(r.ROAs) == 0 {
+		return output
+	}
+
+	return output + "\n\n" + renderTableData(r, colour)
+}
+
+func formatRPKIState(state string, reason string) string {
+	if reason == "" {
+		return state
+	}
+
+	return fmt.Sprintf("%s (%s)", state, reason)
+}
+
+func colourRPKIState(s string, state string, colour bool) string {
+	switch state {
+	case RPKI_STATE_VALID:
+		return colourise(s, ANSI_GREEN, colour)
+	case RPKI_STATE_INVALID:
+		return colourise(s, ANSI_RED, colour)
+	}
+
+	return colourise(s, ANSI_YELLOW, colour)
+}
@@ -0,0 +1,63 @@
+package tool
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestRPKIIndexValidate(t *testing.T) {
+	index := newRPKIIndex([]RPKIROA{
+		{ASN: 64500, Prefix: "192.0.2.0/24", MaxLength: 24},
+		{ASN: 64501, Prefix: "198.51.100.0/22", MaxLength: 23},
+		{ASN: 64502, Prefix: "198.51.100.0/22", MaxLength: 24},
+		// Max length below the prefix length is raised to the prefix length
+		{ASN: 64503, Prefix: "203.0.113.0/24", MaxLength: 16},
+		{ASN: 0, Prefix: "100.64.0.0/10", MaxLength: 32},
+		{ASN: 64504, Prefix: "2001:db8::/32", MaxLength: 48},
+		{ASN: 64505, Prefix: "invalid", MaxLength: 24},
+	})
+
+	if index.count != 6 {
+		t.Errorf("expected 6 ROAs, got %d", index.count)
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		asn      uint32
+		state    string
+		reason   string
+		covering int
+	}{
+		{name: "exact match", prefix: "192.0.2.0/24", asn: 64500, state: RPKI_STATE_VALID, covering: 1},
+		{name: "wrong origin", prefix: "192.0.2.0/24", asn: 64510, state: RPKI_STATE_INVALID, reason: RPKI_REASON_WRONG_ASN, covering: 1},
+		{name: "more specific than max length", prefix: "192.0.2.0/25", asn: 64500, state: RPKI_STATE_INVALID, reason: RPKI_REASON_TOO_SPECIFIC, covering: 1},
+		{name: "more specific with wrong origin", prefix: "192.0.2.0/25", asn: 64510, state: RPKI_STATE_INVALID, reason: RPKI_REASON_WRONG_ASN, covering: 1},
+		{name: "equal to max length", prefix: "198.51.100.0/23", asn: 64501, state: RPKI_STATE_VALID, covering: 2},
+		{name: "beyond max length", prefix: "198.51.100.0/24", asn: 64501, state: RPKI_STATE_INVALID, reason: RPKI_REASON_TOO_SPECIFIC, covering: 2},
+		{name: "valid from second covering ROA", prefix: "198.51.100.0/24", asn: 64502, state: RPKI_STATE_VALID, covering: 2},
+		{name: "raised max length", prefix: "203.0.113.0/24", asn: 64503, state: RPKI_STATE_VALID, covering: 1},
+		{name: "raised max length more specific", prefix: "203.0.113.0/25", asn: 64503, state: RPKI_STATE_INVALID, reason: RPKI_REASON_TOO_SPECIFIC, covering: 1},
+		{name: "AS0 ROA", prefix: "100.64.1.0/24", asn: 64500, state: RPKI_STATE_INVALID, reason: RPKI_REASON_WRONG_ASN, covering: 1},
+		{name: "AS0 ROA with AS0 origin", prefix: "100.64.1.0/24", asn: 0, state: RPKI_STATE_INVALID, reason: RPKI_REASON_WRONG_ASN, covering: 1},
+		{name: "less specific than ROA", prefix: "192.0.0.0/16", asn: 64500, state: RPKI_STATE_NOT_FOUND, covering: 0},
+		{name: "no covering ROA", prefix: "10.0.0.0/8", asn: 64500, state: RPKI_STATE_NOT_FOUND, covering: 0},
+		{name: "IPv6 within max length", prefix: "2001:db8:1::/48", asn: 64504, state: RPKI_STATE_VALID, covering: 1},
+		{name: "IPv6 beyond max length", prefix: "2001:db8:1::/64", asn: 64504, state: RPKI_STATE_INVALID, reason: RPKI_REASON_TOO_SPECIFIC, covering: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, reason, covering := index.validate(netip.MustParsePrefix(test.prefix), test.asn)
+			if state != test.state {
+				t.Errorf("expected state %s, got %s", test.state, state)
+			}
+			if reason != test.reason {
+				t.Errorf("expected reason %q, got %q", test.reason, reason)
+			}
+			if len(covering) != test.covering {
+				t.Errorf("expected %d covering ROAs, got %d", test.covering, len(covering))
+			}
+		})
+	}
+}
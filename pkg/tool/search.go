@@ -0,0 +1,84 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const SEARCH_DEFAULT_LIMIT = 50
+const SEARCH_MAX_LIMIT = 500
+
+type searchOptions struct {
+	Exact bool
+	Page  int
+	Limit int
+}
+
+// newSearchOptions parses ?exact, ?page (from 1) and ?limit from the request
+func newSearchOptions(r *http.Request) (searchOptions, error) {
+	query := r.URL.Query()
+	opts := searchOptions{
+		Exact: query.Has("exact"),
+		Page:  1,
+		Limit: SEARCH_DEFAULT_LIMIT,
+	}
+
+	if page := query.Get("page"); page != "" {
+		parsed, err := strconv.Atoi(page)
+		if err != nil || parsed < 1 {
+			return opts, errors.New("invalid page")
+		}
+		opts.Page = parsed
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > SEARCH_MAX_LIMIT {
+			return opts, fmt.Errorf("invalid limit - must be between 1 and %d", SEARCH_MAX_LIMIT)
+		}
+		opts.Limit = parsed
+	}
+
+	return opts, nil
+}
+
+func (o searchOptions) offset() int {
+	return (o.Page - 1) * o.Limit
+}
+
+// searchTokens splits s into lower cased alphanumeric words
+func searchTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SearchPage describes a single page of ranked search results
+type SearchPage struct {
+	Query string `json:"query"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Total int    `json:"total"`
+}
+
+func newSearchPage(query string, opts searchOptions, total int) SearchPage {
+	return SearchPage{
+		Query: query,
+		Page:  opts.Page,
+		Limit: opts.Limit,
+		Total: total,
+	}
+}
+
+func (p *SearchPage) footer() string {
+	pages := (p.Total + p.Limit - 1) / p.Limit
+	if pages < 1 {
+		pages = 1
+	}
+
+	return fmt.Sprintf("Page %d of %d (%d results)", p.Page, pages, p.Total)
+}
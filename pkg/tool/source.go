@@ -0,0 +1,125 @@
+package tool
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SOURCE_TIMEOUT bounds waiting for a response and each read of its body,
+// rather than the whole transfer, as sources can take minutes to download
+const SOURCE_TIMEOUT = 30 * time.Second
+
+var sourceClient = newSourceClient()
+
+func newSourceClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = SOURCE_TIMEOUT
+
+	return &http.Client{Transport: transport}
+}
+
+// openSource opens a data source from either a URL or a local file path.
+// Sources ending .gz or .bz2 are transparently decompressed
+func openSource(location string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		ctx, cancel := context.WithCancel(context.Background())
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		response, err := sourceClient.Do(request)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			cancel()
+			return nil, fmt.Errorf("unexpected status retrieving %s: %s", location, response.Status)
+		}
+
+		body = newSourceIdleReader(response.Body, cancel)
+	} else {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+
+		body = f
+	}
+
+	if strings.HasSuffix(location, ".bz2") {
+		return &sourceReader{Reader: bzip2.NewReader(body), closers: []io.Closer{body}}, nil
+	}
+
+	if !strings.HasSuffix(location, ".gz") {
+		return body, nil
+	}
+
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	return &sourceReader{Reader: gz, closers: []io.Closer{gz, body}}, nil
+}
+
+// sourceReader closes each wrapped reader in turn
+type sourceReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *sourceReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// sourceIdleReader cancels the request once no data has been read for
+// SOURCE_TIMEOUT, so a stalled transfer fails rather than blocking forever
+type sourceIdleReader struct {
+	body   io.ReadCloser
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func newSourceIdleReader(body io.ReadCloser, cancel context.CancelFunc) *sourceIdleReader {
+	return &sourceIdleReader{
+		body:   body,
+		timer:  time.AfterFunc(SOURCE_TIMEOUT, cancel),
+		cancel: cancel,
+	}
+}
+
+func (r *sourceIdleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.timer.Reset(SOURCE_TIMEOUT)
+
+	return n, err
+}
+
+func (r *sourceIdleReader) Close() error {
+	r.timer.Stop()
+	err := r.body.Close()
+	r.cancel()
+
+	return err
+}
@@ -120,24 +120,32 @@ type SSLChainResponseData struct {
 }
 
 func (r *SSLResponseData) String() string {
-	str := fmt.Sprintf("Valid:    %t\n", r.Valid)
+	return r.render(false)
+}
+
+func (r *SSLResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *SSLResponseData) render(colour bool) string {
+	str := fmt.Sprintf("Valid:    %s\n", colourBool(r.Valid, colour))
 	if !r.Valid {
-		str = str + fmt.Sprintf("Error:    %s\n", r.Error)
+		str = str + fmt.Sprintf("Error:    %s\n", colourise(r.Error, ANSI_RED, colour))
 	}
 	str = str + "\n"
 
 	for i, cert := range r.Chain {
 		if i > 0 {
 			padding := strings.Repeat("       ", i-1)
-			validChar := "✖"
+			validChar := colourise("✖", ANSI_RED, colour)
 			if cert.ValidIssuer {
-				validChar = "✔"
+				validChar = colourise("✔", ANSI_GREEN, colour)
 			}
 			str = str + fmt.Sprintf(`%[1]s│
 %[1]s└─[%[2]s]─ `, padding, validChar)
 		}
 
-		str = str + fmt.Sprintf("%s (Issuer: %s)\n", cert.CommonName, cert.IssuerCommonName)
+		str = str + fmt.Sprintf("%s (Issuer: %s)\n", colourise(cert.CommonName, ANSI_BOLD, colour), cert.IssuerCommonName)
 	}
 
 	return str
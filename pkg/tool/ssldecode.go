@@ -4,7 +4,6 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -97,20 +96,31 @@ type SSLDecodeResponseData struct {
 }
 
 func (r *SSLDecodeResponseData) String() string {
-	return fmt.Sprintf(`> Certificate
-Common Name:           %s
-SANs:                  %s
-Organisation:          %s
-City:                  %s
-Country:               %s
-Serial:                %s
-Valid From:            %s
-Valid To:              %s
+	return r.render(false)
+}
+
+func (r *SSLDecodeResponseData) ColourString() string {
+	return r.render(true)
+}
 
-> Issuer
-Issuer Name:           %s
-Issuer Organisation:   %s
-Issuer City:           %s
-Issuer Country:        %s
-Issuer Serial:         %s`, r.CommonName, strings.Join(r.SANs, ", "), strings.Join(r.Organisation, ", "), strings.Join(r.City, ", "), strings.Join(r.Country, ", "), r.Serial, r.ValidFrom, r.ValidTo, r.IssuerName, strings.Join(r.IssuerOrganisation, ", "), strings.Join(r.IssuerCity, ", "), strings.Join(r.IssuerCountry, ", "), r.IssuerSerial)
+func (r *SSLDecodeResponseData) render(colour bool) string {
+	return colourise("> Certificate", ANSI_BOLD, colour) + "\n" +
+		renderKeyValues(23, colour,
+			keyValue{"Common Name:", r.CommonName},
+			keyValue{"SANs:", strings.Join(r.SANs, ", ")},
+			keyValue{"Organisation:", strings.Join(r.Organisation, ", ")},
+			keyValue{"City:", strings.Join(r.City, ", ")},
+			keyValue{"Country:", strings.Join(r.Country, ", ")},
+			keyValue{"Serial:", r.Serial},
+			keyValue{"Valid From:", r.ValidFrom},
+			keyValue{"Valid To:", r.ValidTo},
+		) + "\n\n" +
+		colourise("> Issuer", ANSI_BOLD, colour) + "\n" +
+		renderKeyValues(23, colour,
+			keyValue{"Issuer Name:", r.IssuerName},
+			keyValue{"Issuer Organisation:", strings.Join(r.IssuerOrganisation, ", ")},
+			keyValue{"Issuer City:", strings.Join(r.IssuerCity, ", ")},
+			keyValue{"Issuer Country:", strings.Join(r.IssuerCountry, ", ")},
+			keyValue{"Issuer Serial:", r.IssuerSerial},
+		)
 }
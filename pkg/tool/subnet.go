@@ -6,19 +6,29 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 
+	"github.com/0x4c6565/lee.io/internal/pkg/util"
 	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-sockaddr"
 	"github.com/rs/zerolog/log"
 )
 
-type Subnet struct{}
+type Subnet struct {
+	specialPurpose *util.SpecialPurposeRegistry
+}
 
 func NewSubnet() *Subnet {
 	return &Subnet{}
 }
 
+// WithSpecialPurpose annotates subnets within special-purpose address space
+func (s *Subnet) WithSpecialPurpose(registry *util.SpecialPurposeRegistry) *Subnet {
+	s.specialPurpose = registry
+	return s
+}
+
 func (s *Subnet) Paths() []string {
 	return []string{
 		"/subnet",
@@ -89,6 +99,10 @@ func (s *Subnet) Handle(r *http.Request) (*ToolResponse, error) {
 		resp.LastUsable = parsedIPPrefix.LastUsable().String()
 	}
 
+	if prefix, err := netip.ParsePrefix(fmt.Sprintf("%s/%d", resp.NetworkAddress, cidr)); err == nil {
+		resp.Special = specialPurposeAnnotation(s.specialPurpose, prefix)
+	}
+
 	return NewToolResponse(resp), nil
 }
 
@@ -102,16 +116,33 @@ type SubnetResponseData struct {
 	AvailableHosts   int    `json:"available_hosts"`
 	FirstUsable      string `json:"first_usable"`
 	LastUsable       string `json:"last_usable"`
+	Special          string `json:"special,omitempty"`
 }
 
 func (r *SubnetResponseData) String() string {
-	return fmt.Sprintf(`Address:            %s
-Netmask:            %s
-CIDR:               %d
-Network Address:    %s
-Broadcast Address:  %s
-Total Hosts:        %d
-Available Hosts:    %d
-First Usable:       %s
-Last Usable:        %s`, r.Address, r.Netmask, r.CIDR, r.NetworkAddress, r.BroadcastAddress, r.TotalHosts, r.AvailableHosts, r.FirstUsable, r.LastUsable)
+	return r.render(false)
+}
+
+func (r *SubnetResponseData) ColourString() string {
+	return r.render(true)
+}
+
+func (r *SubnetResponseData) render(colour bool) string {
+	pairs := []keyValue{
+		{"Address:", r.Address},
+		{"Netmask:", r.Netmask},
+		{"CIDR:", strconv.Itoa(r.CIDR)},
+		{"Network Address:", r.NetworkAddress},
+		{"Broadcast Address:", r.BroadcastAddress},
+		{"Total Hosts:", strconv.Itoa(r.TotalHosts)},
+		{"Available Hosts:", strconv.Itoa(r.AvailableHosts)},
+		{"First Usable:", r.FirstUsable},
+		{"Last Usable:", r.LastUsable},
+	}
+
+	if r.Special != "" {
+		pairs = append(pairs, keyValue{"Special:", r.Special})
+	}
+
+	return renderKeyValues(20, colour, pairs...)
 }
@@ -15,6 +15,19 @@ type ToolResponseData interface {
 	String() string
 }
 
+type ToolResponseDataColour interface {
+	ColourString() string
+}
+
+type ToolResponseDataTable interface {
+	Header() []string
+	Rows() [][]string
+}
+
+type ToolResponseDataList interface {
+	Items() []interface{}
+}
+
 type ToolResponseString string
 
 func NewToolResponseString(s string) *ToolResponseString {
@@ -46,6 +59,19 @@ func NewToolResponse(d ToolResponseData) *ToolResponse {
 	}
 }
 
+type ToolStreamFunc func(data ToolResponseData) error
+
+type ToolStream interface {
+	HandleStream(r *http.Request, emit ToolStreamFunc) error
+}
+
+// ToolStart is implemented by tools with work to do once the server starts,
+// which is run before requests are served. Long running work, such as loading
+// data, should be started in the background
+type ToolStart interface {
+	Start()
+}
+
 type CronSpec struct {
 	Cron string
 	Func func()
@@ -2,6 +2,7 @@ package tool
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -33,6 +34,19 @@ func (w *Whois) Method() string {
 }
 
 func (w *Whois) Handle(r *http.Request) (*ToolResponse, error) {
+	var response ToolResponseData
+	err := w.HandleStream(r, func(data ToolResponseData) error {
+		response = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewToolResponse(response), nil
+}
+
+func (w *Whois) HandleStream(r *http.Request, emit ToolStreamFunc) error {
 	vars := mux.Vars(r)
 
 	host, ok := vars["host"]
@@ -40,36 +54,53 @@ func (w *Whois) Handle(r *http.Request) (*ToolResponse, error) {
 		host = util.GetSourceIPAddress(r)
 	}
 
-	whoisServer, err := w.doQuery("whois.iana.org", host)
+	return w.lookup(host, func(server string, response []byte) error {
+		return emit(NewToolResponseString(fmt.Sprintf("Whois server: %s\n\n%s", server, response)))
+	})
+}
+
+// lookup queries IANA for the authoritative whois server for host, then
+// queries that server, calling step with each server's response
+func (w *Whois) lookup(host string, step func(server string, response []byte) error) error {
+	ianaResponse, err := w.readQuery("whois.iana.org", host)
 	if err != nil {
-		log.Error().Err(err).Send()
-		return nil, errors.New("failed to query whois server")
+		return err
 	}
 
-	defer whoisServer.Close()
+	err = step("whois.iana.org", ianaResponse)
+	if err != nil {
+		return err
+	}
 
-	parsedWhoisServer, err := w.parseWhoisServer(whoisServer)
+	parsedWhoisServer, err := w.parseWhoisServer(bytes.NewReader(ianaResponse))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	targetWhoisServer, err := w.doQuery(parsedWhoisServer, host)
+	response, err := w.readQuery(parsedWhoisServer, host)
+	if err != nil {
+		return err
+	}
+
+	return step(parsedWhoisServer, response)
+}
+
+func (w *Whois) readQuery(server, query string) ([]byte, error) {
+	conn, err := w.doQuery(server, query)
 	if err != nil {
 		log.Error().Err(err).Send()
 		return nil, errors.New("failed to query whois server")
 	}
 
-	defer targetWhoisServer.Close()
+	defer conn.Close()
 
-	response, err := io.ReadAll(targetWhoisServer)
+	response, err := io.ReadAll(conn)
 	if err != nil {
 		log.Error().Err(err).Send()
 		return nil, errors.New("failed to read whois response")
 	}
 
-	return NewToolResponse(
-		NewToolResponseString(fmt.Sprintf("Whois server: %s\n\n%s", parsedWhoisServer, response)),
-	), nil
+	return response, nil
 }
 
 func (w *Whois) parseWhoisServer(response io.Reader) (string, error) {
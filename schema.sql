@@ -1,10 +1,40 @@
 CREATE TABLE `mac_oui` (
   `id` int NOT NULL AUTO_INCREMENT,
-  `oui` text NOT NULL,
+  `oui` varchar(12) NOT NULL,
   `company_name` text NOT NULL,
+  `registry` varchar(8) NOT NULL DEFAULT 'MA-L',
+  `block_size` int NOT NULL DEFAULT 24,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `mac_oui_oui_idx` (`oui`),
+  FULLTEXT KEY `mac_oui_company_name_idx` (`company_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `mac_oui_refresh` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `started_at` datetime NOT NULL,
+  `completed_at` datetime NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `error` text NOT NULL,
+  `total` int NOT NULL,
+  `added` int NOT NULL,
+  `removed` int NOT NULL,
+  `renamed` int NOT NULL,
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE `mac_oui_change` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `refresh_id` int NOT NULL,
+  `change_type` varchar(16) NOT NULL,
+  `oui` varchar(12) NOT NULL,
+  `registry` varchar(8) NOT NULL,
+  `block_size` int NOT NULL,
+  `company_name` text NOT NULL,
+  `previous_company_name` text NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `mac_oui_change_refresh_idx` (`refresh_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 CREATE TABLE `bgp_route` (
   `id` varchar(36) NOT NULL PRIMARY KEY,
   `version` int(11) NOT NULL,
@@ -16,9 +46,21 @@ CREATE TABLE `bgp_route` (
   `ipv4_start` bigint(20) NOT NULL,
   `ipv4_end` bigint(20) NOT NULL,
   `ipv6_start` varchar(45) NOT NULL,
-  `ipv6_end` varchar(45) NOT NULL
+  `ipv6_end` varchar(45) NOT NULL,
+  `as_path` text NOT NULL,
+  `upstreams` text NOT NULL,
+  `peer_count` int(11) NOT NULL DEFAULT 0,
+  `visibility` double NOT NULL DEFAULT 0,
+  KEY `bgp_route_version_idx` (`version`),
+  KEY `bgp_route_route_idx` (`route`(64))
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 CREATE TABLE `bgp_route_version` (
   `version` int(11) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `bgp_snapshot` (
+  `version` int(11) NOT NULL PRIMARY KEY,
+  `created_at` datetime NOT NULL,
+  `route_count` int(11) NOT NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
@@ -0,0 +1,13 @@
+package static
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed lee.io
+var files embed.FS
+
+func LeeIO() (fs.FS, error) {
+	return fs.Sub(files, "lee.io")
+}